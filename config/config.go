@@ -0,0 +1,187 @@
+// Package config describes the whole grank pipeline, from listing the repositories to ranking
+// the modules, in a single JSON file consumed by lsrepo, fetcharchive and modranker. The tools
+// read it from their -config flag and their other command line flags override its values.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config is the configuration of the pipeline, the relative paths are relative to the DataDir.
+type Config struct {
+	DataDir string `json:"data_dir"`
+	Token   Token  `json:"token"`
+	// Repos is the repos json file written by lsrepo and read by fetcharchive and modranker.
+	Repos string `json:"repos"`
+	// DownloadDir is the directory fetcharchive downloads the repositories to and modranker reads them from.
+	DownloadDir string `json:"download_dir"`
+	List        List   `json:"list"`
+	Fetch       Fetch  `json:"fetch"`
+	Rank        Rank   `json:"rank"`
+}
+
+// Token is the source of the GitHub API token: the Env environment variable, GH_TOKEN by default,
+// or the File when the variable is not set.
+type Token struct {
+	Env  string `json:"env"`
+	File string `json:"file"`
+}
+
+// List configures lsrepo.
+type List struct {
+	// Queries are the search partitions, every query is paged through by decreasing stars,
+	// e.g. "language:go created:<2016-01-01". The default is "language:go".
+	Queries []string `json:"queries"`
+	// Previous are the repos json files of earlier runs, their repositories missing from the
+	// search results are fetched one by one.
+	Previous []string `json:"previous"`
+}
+
+// Fetch configures fetcharchive, ExcludeDirs and Keep are its retention policy.
+type Fetch struct {
+	Concurrency int `json:"concurrency"`
+	// ExcludeDirs are the directories left out from the extracted archives, the stored dependencies by default.
+	ExcludeDirs []string `json:"exclude_dirs"`
+	// Keep are file name patterns kept besides the go, module and license files, e.g. "*.proto".
+	Keep []string `json:"keep"`
+}
+
+// Rank configures modranker.
+type Rank struct {
+	Weighting       string   `json:"weighting"`
+	HalfLife        string   `json:"half_life"`
+	Alpha           float64  `json:"alpha"`
+	Tolerance       float64  `json:"tolerance"`
+	ExcludeInternal bool     `json:"exclude_internal"`
+	NoTest          bool     `json:"no_test"`
+	NoTool          bool     `json:"no_tool"`
+	Seeds           []string `json:"seeds"`
+	Orgs            []string `json:"orgs"`
+	// Graph is the output dependency graph file, Table the ranking table.
+	Graph       string `json:"graph"`
+	Table       string `json:"table"`
+	TableFormat string `json:"table_format"`
+	Columns     string `json:"columns"`
+	SQLite      string `json:"sqlite"`
+	Snapshots   string `json:"snapshots"`
+	// Flags sets any other modranker flag by its name.
+	Flags map[string]string `json:"flags"`
+}
+
+// Load reads the config file.
+func Load(fn string) (*Config, error) {
+	c, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err = json.Unmarshal(c, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", fn, err)
+	}
+	return cfg, nil
+}
+
+// Path resolves the path relative to the DataDir, it leaves empty and absolute paths unchanged.
+func (c *Config) Path(p string) string {
+	if p == "" || filepath.IsAbs(p) || c.DataDir == "" {
+		return p
+	}
+	return filepath.Join(c.DataDir, p)
+}
+
+// GitHubToken returns the GitHub API token from the configured source.
+func (c *Config) GitHubToken() (string, error) {
+	env := c.Token.Env
+	if env == "" {
+		env = "GH_TOKEN"
+	}
+	if t := os.Getenv(env); t != "" {
+		return t, nil
+	}
+	if c.Token.File == "" {
+		return "", fmt.Errorf("%s env var has to contain a valid github api access token", env)
+	}
+	t, err := ioutil.ReadFile(c.Path(c.Token.File))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token: %v", err)
+	}
+	return strings.TrimSpace(string(t)), nil
+}
+
+// FromArgs loads the config file given by the -config flag of the command line arguments, it
+// returns an empty config when there is none. The file has to be known before the flags are
+// parsed as its values become the defaults of the flags.
+func FromArgs(args []string) (*Config, error) {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		name := strings.TrimLeft(a, "-")
+		if len(name) == len(a) {
+			continue
+		}
+		if v := strings.TrimPrefix(name, "config="); v != name {
+			return Load(v)
+		}
+		if name == "config" && i+1 < len(args) {
+			return Load(args[i+1])
+		}
+	}
+	return &Config{}, nil
+}
+
+// Flag registers the -config flag on the flag set, its value is read by FromArgs.
+func Flag(fs *flag.FlagSet) {
+	fs.String("config", "", "JSON config file of the pipeline, the flags override its values")
+}
+
+// Flags collects flag values, the zero values are skipped.
+type Flags map[string]string
+
+func (f Flags) String(name, v string) {
+	if v != "" {
+		f[name] = v
+	}
+}
+
+func (f Flags) Int(name string, v int) {
+	if v != 0 {
+		f[name] = strconv.Itoa(v)
+	}
+}
+
+func (f Flags) Float(name string, v float64) {
+	if v != 0 {
+		f[name] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func (f Flags) Bool(name string, v bool) {
+	if v {
+		f[name] = "true"
+	}
+}
+
+func (f Flags) List(name string, v []string) {
+	f.String(name, strings.Join(v, ","))
+}
+
+// Set sets the flags of the flag set, before parsing the command line so that it overrides them.
+func (f Flags) Set(fs *flag.FlagSet) error {
+	for name, v := range f {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %s in the config", name)
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("invalid value %q of flag %s in the config: %v", v, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFromArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "grank.json")
+	if err = ioutil.WriteFile(fn, []byte(`{"data_dir": "data"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		want string // data dir
+	}{
+		{"none", []string{"-v"}, ""},
+		{"separate value", []string{"-v", "-config", fn}, "data"},
+		{"double dash", []string{"--config", fn}, "data"},
+		{"equals", []string{"-config=" + fn, "-v"}, "data"},
+		{"after terminator", []string{"--", "-config", fn}, ""},
+		{"value of another flag", []string{"-o", "config"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := FromArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DataDir != tt.want {
+				t.Errorf("got data dir %q, want %q", cfg.DataDir, tt.want)
+			}
+		})
+	}
+}
+
+// The defaults of the flags are overridden by the config, which is overridden by the command line.
+func TestFlagsOverrideOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Flags
+		args   []string
+		want   string
+	}{
+		{"default", Flags{}, nil, "default"},
+		{"config", Flags{"o": "config"}, nil, "config"},
+		{"command line", Flags{}, []string{"-o", "cli"}, "cli"},
+		{"command line over config", Flags{"o": "config"}, []string{"-o", "cli"}, "cli"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			o := fs.String("o", "default", "")
+			if err := tt.config.Set(fs); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *o != tt.want {
+				t.Errorf("got %q, want %q", *o, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagsSetErrors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("n", 0, "")
+	for _, f := range []Flags{{"unknown": "1"}, {"n": "x"}} {
+		if err := f.Set(fs); err == nil {
+			t.Errorf("%v: no error", f)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	abs, err := filepath.Abs("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dataDir, path, want string
+	}{
+		{"", "repos.json", "repos.json"},
+		{"data", "repos.json", filepath.Join("data", "repos.json")},
+		{"data", "", ""},
+		{"data", abs, abs},
+	}
+	for _, tt := range tests {
+		c := &Config{DataDir: tt.dataDir}
+		if got := c.Path(tt.path); got != tt.want {
+			t.Errorf("Path(%q) with data dir %q = %q, want %q", tt.path, tt.dataDir, got, tt.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/alixaxel/pagerank v0.0.0-20160306110729-14bfb4c1d88c
 	github.com/google/go-github v17.0.0+incompatible
 	github.com/google/go-querystring v1.0.0 // indirect
-	golang.org/x/mod v0.2.0
+	github.com/mattn/go-sqlite3 v1.14.16
+	golang.org/x/mod v0.12.0
 	golang.org/x/oauth2 v0.0.0-20190130055435-99b60b757ec1
 )
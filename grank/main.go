@@ -0,0 +1,52 @@
+// Command grank runs the tools of the pipeline as subcommands and the whole pipeline with caching.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hullarb/grank/fetcharchive/fetcher"
+	"github.com/hullarb/grank/lsrepo/lister"
+	"github.com/hullarb/grank/modranker/ranker"
+)
+
+const usage = `Usage: grank <command> [arguments]
+
+The commands are:
+
+	list    list the go repositories on GitHub, as lsrepo
+	fetch   download the listed repositories, as fetcharchive
+	rank    rank the modules of the downloaded repositories, as modranker,
+	        its other commands are available as grank rank <command>, e.g. grank rank explain
+	serve   serve the query API over a dependency graph, as modranker serve
+	diff    report the rank movements between two rankings, as modranker diff
+	run     run list, fetch and rank as configured by -config, skipping the
+	        stages whose inputs did not change since their last run
+
+Run grank <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "list":
+		lister.Main(args)
+	case "fetch":
+		fetcher.Main(args)
+	case "rank":
+		ranker.Main(args)
+	case "serve", "diff":
+		ranker.Main(os.Args[1:])
+	case "run":
+		runCmd(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "grank: unknown command %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
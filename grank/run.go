@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hullarb/grank/config"
+	"github.com/hullarb/grank/fetcharchive/fetcher"
+	"github.com/hullarb/grank/lsrepo/lister"
+	"github.com/hullarb/grank/modranker/ranker"
+)
+
+// cacheFile stores the input keys of the last successful run of the stages, in the data dir.
+const cacheFile = ".grank-cache.json"
+
+// stage is a step of the pipeline, it is skipped when its output exists and the key of its
+// inputs (its config and the content of its input files and directories) is the same as at its
+// last run.
+type stage struct {
+	name   string
+	main   func(args []string)
+	config interface{}
+	inputs []string
+	// after are the earlier stages whose output directory is an input, keyed by the time of their
+	// last run, walking a directory like the downloaded repos takes long even when nothing changed
+	after  []string
+	output string
+	// maxAge is the age after which the output is stale regardless of the inputs, 0 means never
+	maxAge time.Duration
+}
+
+func runCmd(args []string) {
+	fl := flag.NewFlagSet("run", flag.ExitOnError)
+	cf := fl.String("config", "", "JSON config file of the pipeline")
+	force := fl.Bool("force", false, "run all the stages regardless of the cache")
+	listAge := fl.Duration("list-max-age", 24*time.Hour, "age after which the repository list is fetched again, 0 means only when its inputs change")
+	fl.Parse(args)
+	if *cf == "" {
+		fl.Usage()
+		os.Exit(2)
+	}
+	cfg, err := config.Load(*cf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Repos == "" || cfg.DownloadDir == "" || cfg.Rank.Graph == "" {
+		log.Fatal("the config has to set repos, download_dir and rank.graph to run the pipeline")
+	}
+	var previous []string
+	for _, p := range cfg.List.Previous {
+		previous = append(previous, cfg.Path(p))
+	}
+	// the top level settings locate the inputs and outputs of every stage
+	paths := struct {
+		DataDir, Repos, DownloadDir string
+	}{cfg.DataDir, cfg.Repos, cfg.DownloadDir}
+	stages := []stage{
+		{name: "list", main: lister.Main, config: []interface{}{paths, cfg.List}, inputs: previous, output: cfg.Path(cfg.Repos), maxAge: *listAge},
+		{name: "fetch", main: fetcher.Main, config: []interface{}{paths, cfg.Fetch}, inputs: []string{cfg.Path(cfg.Repos)}, output: cfg.Path(cfg.DownloadDir)},
+		{name: "rank", main: ranker.Main, config: []interface{}{paths, cfg.Rank}, inputs: ranker.Inputs(cfg), after: []string{"fetch"}, output: cfg.Path(cfg.Rank.Graph)},
+	}
+
+	cfn := cfg.Path(cacheFile)
+	c := runCache{Keys: map[string]string{}, Runs: map[string]time.Time{}}
+	if b, err := ioutil.ReadFile(cfn); err == nil {
+		if err = json.Unmarshal(b, &c); err != nil {
+			log.Printf("ignoring invalid cache %s: %v", cfn, err)
+		}
+	}
+	for _, s := range stages {
+		key, err := s.key(c.Runs)
+		if err != nil {
+			log.Fatalf("failed to compute the input key of %s: %v", s.name, err)
+		}
+		if !*force && c.Keys[s.name] == key && s.fresh() {
+			log.Printf("skipping %s, its inputs did not change", s.name)
+			continue
+		}
+		log.Printf("running %s", s.name)
+		s.main([]string{"-config", *cf})
+		// the next stages have to be keyed by the new output
+		c.Keys[s.name] = key
+		c.Runs[s.name] = time.Now().UTC()
+		if err = writeCache(cfn, c); err != nil {
+			log.Fatalf("failed to write the cache %s: %v", cfn, err)
+		}
+	}
+}
+
+// runCache is the content of the cacheFile: the input keys and the time of the last successful
+// run of the stages.
+type runCache struct {
+	Keys map[string]string    `json:"keys"`
+	Runs map[string]time.Time `json:"runs"`
+}
+
+// fresh tells whether the output of the stage exists and is younger than its max age.
+func (s stage) fresh() bool {
+	fi, err := os.Stat(s.output)
+	if err != nil {
+		return false
+	}
+	return s.maxAge == 0 || time.Since(fi.ModTime()) < s.maxAge
+}
+
+// key hashes the config of the stage, its input files and the files under its input directories
+// by their content and the time of the last run of the stages it comes after.
+func (s stage) key(runs map[string]time.Time) (string, error) {
+	h := sha256.New()
+	c, err := json.Marshal(s.config)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s\n%s\n", s.name, c)
+	for _, in := range s.inputs {
+		err = hashPath(h, in)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "missing %s\n", in)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, a := range s.after {
+		fmt.Fprintf(h, "after %s %d\n", a, runs[a].UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPath hashes the file at path or the files under the directory at path in lexical order.
+func hashPath(h hash.Hash, path string) error {
+	// the walk does not follow a symlinked root
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	return filepath.WalkDir(path, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		return hashFile(h, fn)
+	})
+}
+
+func hashFile(h hash.Hash, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(h, "file %s\n", fn)
+	_, err = io.Copy(h, f)
+	return err
+}
+
+func writeCache(fn string, cache runCache) error {
+	c, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, c, 0644)
+}
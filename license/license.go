@@ -0,0 +1,35 @@
+// Package license knows the names of the license files, fetcharchive keeps them and modranker classifies them.
+package license
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// filePrefixes are the upper case stems of the license file names.
+var filePrefixes = []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"}
+
+// fileExts are the upper case extensions of the license files besides none.
+var fileExts = []string{".MD", ".TXT", ".RST", ".MARKDOWN", ".HTML"}
+
+// IsFile tells whether the file is a license file like LICENSE, LICENSE-MIT.md, COPYING.LESSER
+// or UNLICENSE: one of the stems, optionally followed by a variant after a '-', '_' or '.', and
+// one of the extensions. Go sources like license.go are not license files.
+func IsFile(name string) bool {
+	n := strings.ToUpper(name)
+	if filepath.Ext(n) == ".GO" {
+		return false
+	}
+	for _, e := range fileExts {
+		if strings.HasSuffix(n, e) {
+			n = strings.TrimSuffix(n, e)
+			break
+		}
+	}
+	for _, p := range filePrefixes {
+		if n == p || strings.HasPrefix(n, p+"-") || strings.HasPrefix(n, p+"_") || strings.HasPrefix(n, p+".") {
+			return true
+		}
+	}
+	return false
+}
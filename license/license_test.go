@@ -0,0 +1,30 @@
+package license
+
+import "testing"
+
+func TestIsFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"LICENSE", true},
+		{"license.md", true},
+		{"LICENSE-MIT", true},
+		{"Licence.txt", true},
+		{"COPYING.LESSER", true},
+		{"UNLICENSE", true},
+		{"README.md", false},
+		{"LICENSE.MIT", true},
+		{"LICENSE_1_0.txt", true},
+		{"licenses.go", false},
+		{"license.go", false},
+		{"LICENSES.md", false},
+		{"licensing.md", false},
+		{"go.mod", false},
+	}
+	for _, tt := range tests {
+		if got := IsFile(tt.name); got != tt.want {
+			t.Errorf("IsFile(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
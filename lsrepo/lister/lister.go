@@ -0,0 +1,220 @@
+package lister
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/config"
+	"golang.org/x/oauth2"
+)
+
+// listing is the state of a listing: the repos written to out so far. Main starts a new one on
+// every call.
+type listing struct {
+	all     int
+	found   map[string]struct{}
+	client  *github.Client
+	jsonEnc *json.Encoder
+	out     *os.File
+}
+
+// Main runs lsrepo with the command line arguments, without the program name.
+func Main(args []string) {
+	fl := flag.NewFlagSet("lsrepo", flag.ExitOnError)
+	log.SetFlags(log.Lmicroseconds)
+	qs := fl.String("q", "language:go", "comma separated search queries partitioning the repositories, each is paged through by decreasing stars")
+	config.Flag(fl)
+	fl.Usage = func() {
+		fmt.Println("Usage: ./lsrepo [flags] out_file_name [older repos json files]")
+		fmt.Println()
+		fmt.Println("out_file_name: a file with the name will be created with a json array of the fetched github repos")
+		fmt.Println("older repos json files: results of earlier runs to ensure that all the repositoreis from those files are fetched")
+		fmt.Println("both default to the values of the config")
+		fmt.Println()
+		fl.PrintDefaults()
+	}
+	cfg, err := config.FromArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f := config.Flags{}
+	f.List("q", cfg.List.Queries)
+	if err = f.Set(fl); err != nil {
+		log.Fatal(err)
+	}
+	fl.Parse(args)
+	token, err := cfg.GitHubToken()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	outFile, previous := cfg.Path(cfg.Repos), make([]string, len(cfg.List.Previous))
+	for i, p := range cfg.List.Previous {
+		previous[i] = cfg.Path(p)
+	}
+	if fl.NArg() > 0 {
+		outFile, previous = fl.Arg(0), fl.Args()[1:]
+	}
+	if outFile == "" {
+		fl.Usage()
+		os.Exit(1)
+	}
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	ctx := context.Background()
+	tc := oauth2.NewClient(ctx, ts)
+	out, err := os.Create(outFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	_, err = out.WriteString("[")
+	if err != nil {
+		log.Fatal(err)
+	}
+	l := &listing{
+		found:   map[string]struct{}{},
+		client:  github.NewClient(tc),
+		jsonEnc: json.NewEncoder(out),
+		out:     out,
+	}
+	for _, base := range strings.Split(*qs, ",") {
+		base = strings.TrimSpace(base)
+		if base == "" {
+			continue
+		}
+		var lastStars int
+		for {
+			q := base
+			if lastStars != 0 {
+				q += fmt.Sprintf(" stars:<=%v", lastStars)
+			}
+			last := l.getAllPages(q)
+			if last == lastStars {
+				log.Printf("last start count %d did not change, exiting", lastStars)
+				break
+			}
+			lastStars = last
+		}
+	}
+	log.Printf("fetched %d repos from API", l.all)
+	l.fetchMissing(previous)
+	_, err = out.WriteString("]")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (l *listing) fetchMissing(repoFiles []string) {
+	for _, f := range repoFiles {
+		inp, err := os.Open(f)
+		if err != nil {
+			log.Fatalf("failed to open: %v", err)
+		}
+		var rs []github.Repository
+		err = json.NewDecoder(inp).Decode(&rs)
+		if err != nil {
+			log.Fatalf("failed to decode: %v", err)
+		}
+		for _, r := range rs {
+			if _, ok := l.found[r.GetFullName()]; ok {
+				continue
+			}
+			log.Printf("fetching missing: %s", r.GetFullName())
+			stop := false
+			for !stop {
+				rp, resp, err := l.client.Repositories.Get(context.Background(), r.GetOwner().GetLogin(), r.GetName())
+				if err != nil {
+					if _, ok := err.(*github.RateLimitError); ok {
+						log.Println("hit rate limit")
+						time.Sleep(10 * time.Second)
+						continue
+					} else {
+						log.Printf("failed to fetch %s: %v", r.GetFullName(), err)
+						stop = true
+						continue
+					}
+				}
+				if l.all > 0 {
+					if _, err = l.out.Write([]byte{',', '\n'}); err != nil {
+						log.Fatal(err)
+					}
+				}
+				if err = l.jsonEnc.Encode(rp); err != nil {
+					log.Fatal(err)
+				}
+				l.found[rp.GetFullName()] = struct{}{}
+				if resp.Rate.Remaining == 0 {
+					s := resp.Rate.Reset.Time.Sub(time.Now())
+					log.Printf("quota exhausted, sleeping %v", s)
+					time.Sleep(s)
+				}
+				l.all++
+				break
+			}
+		}
+	}
+}
+
+func (l *listing) getAllPages(q string) int {
+	opt := &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+		Sort:        "stars",
+	}
+	var last int
+	log.Printf("q: %s", q)
+	for {
+		ctx := context.Background()
+		repos, resp, err := l.client.Search.Repositories(ctx, q, opt)
+		if err != nil {
+			if _, ok := err.(*github.RateLimitError); ok {
+				log.Println("hit rate limit")
+				time.Sleep(10 * time.Second)
+				continue
+			} else {
+				log.Fatal(err)
+			}
+		}
+		if repos.GetIncompleteResults() {
+			log.Printf("incomplete results, retrying with %s", q)
+			continue
+		}
+		for _, r := range repos.Repositories {
+			// the partitions and the pages by stars overlap
+			if _, ok := l.found[r.GetFullName()]; ok {
+				last = r.GetStargazersCount()
+				continue
+			}
+			if l.all > 0 {
+				if _, err = l.out.Write([]byte{',', '\n'}); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if err = l.jsonEnc.Encode(r); err != nil {
+				log.Fatal(err)
+			}
+			l.all++
+			last = r.GetStargazersCount()
+			l.found[r.GetFullName()] = struct{}{}
+		}
+		log.Printf("all: %d, last star count: %v, rate: %v", l.all, last, resp.Rate)
+		if resp.NextPage == 0 {
+			break
+		}
+		if resp.Rate.Remaining == 0 {
+			s := resp.Rate.Reset.Time.Sub(time.Now())
+			log.Printf("quota exhausted, sleeping %v", s)
+			time.Sleep(s)
+		}
+		opt.Page = resp.NextPage
+	}
+	return last
+}
@@ -0,0 +1,460 @@
+package dgraph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The binary format stores every string once in a string table and only the upstream edges of the
+// graph as adjacency lists of id deltas. The fields of the records are described by schemas,
+// so readers skip the fields they don't know and files written before a field was added stay readable.
+// All integers are varints:
+//
+//	"GRDG" version
+//	string table: string count, then the length and the bytes of every string
+//	graph, pkg and dependency schemas: field count, then the name (string index) and type of every field
+//	graph fields (besides Pkgs and Deps)
+//	pkg count, then the fields of every pkg
+//	module count, then for every module with dependencies in increasing id order: the id delta,
+//	the dependency count and for every dependency in increasing id order: the id delta and the fields
+//
+// The lengths of the string lists, the pkg and the module counts are stored incremented by one,
+// 0 stands for a nil slice or map, so that empty and nil values stay apart.
+// The counts read from a file are not trusted, the decoded values only grow with the input read.
+const (
+	magic   = "GRDG"
+	version = 1
+)
+
+// field types
+const (
+	stringField = 's'
+	boolField   = 'b'
+	intField    = 'i'
+	uintField   = 'u'
+	floatField  = 'f'
+	listField   = 'l' // []string
+	jsonField   = 'j' // anything else, stored as a JSON string
+)
+
+var errCorrupt = errors.New("corrupt binary dependency graph")
+
+var (
+	graphType = reflect.TypeOf(Graph{})
+	pkgType   = reflect.TypeOf(Pkg{})
+	depType   = reflect.TypeOf(Dependency{})
+)
+
+type field struct {
+	name  string
+	typ   byte
+	index int // of the struct field, -1 when unknown to the reader
+}
+
+// schema returns the fields of the struct type t besides the skipped ones.
+func schema(t reflect.Type, skip ...string) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		n := jsonName(t.Field(i))
+		if n == "" {
+			continue
+		}
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || s == n
+		}
+		if !skipped {
+			fs = append(fs, field{name: n, typ: fieldType(t.Field(i).Type), index: i})
+		}
+	}
+	return fs
+}
+
+func jsonName(sf reflect.StructField) string {
+	if sf.PkgPath != "" {
+		return ""
+	}
+	n := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if n == "-" {
+		return ""
+	}
+	if n == "" {
+		n = sf.Name
+	}
+	return n
+}
+
+func fieldType(t reflect.Type) byte {
+	switch t.Kind() {
+	case reflect.String:
+		return stringField
+	case reflect.Bool:
+		return boolField
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intField
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintField
+	case reflect.Float32, reflect.Float64:
+		return floatField
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return listField
+		}
+	}
+	return jsonField
+}
+
+type encoder struct {
+	body bytes.Buffer
+	strs map[string]uint64
+	list []string
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	e.body.Write(e.buf[:n])
+}
+
+// length writes the length of a slice or map, keeping nil and empty apart.
+func (e *encoder) length(v reflect.Value) {
+	if v.IsNil() {
+		e.uvarint(0)
+		return
+	}
+	e.uvarint(uint64(v.Len()) + 1)
+}
+
+func (e *encoder) str(s string) {
+	i, ok := e.strs[s]
+	if !ok {
+		i = uint64(len(e.list))
+		e.strs[s] = i
+		e.list = append(e.list, s)
+	}
+	e.uvarint(i)
+}
+
+func (e *encoder) schema(fs []field) {
+	e.uvarint(uint64(len(fs)))
+	for _, f := range fs {
+		e.str(f.name)
+		e.body.WriteByte(f.typ)
+	}
+}
+
+func (e *encoder) fields(v reflect.Value, fs []field) error {
+	for _, f := range fs {
+		fv := v.Field(f.index)
+		switch f.typ {
+		case stringField:
+			e.str(fv.String())
+		case boolField:
+			var b byte
+			if fv.Bool() {
+				b = 1
+			}
+			e.body.WriteByte(b)
+		case intField:
+			n := binary.PutVarint(e.buf[:], fv.Int())
+			e.body.Write(e.buf[:n])
+		case uintField:
+			e.uvarint(fv.Uint())
+		case floatField:
+			binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(fv.Float()))
+			e.body.Write(e.buf[:8])
+		case listField:
+			e.length(fv)
+			for i := 0; i < fv.Len(); i++ {
+				e.str(fv.Index(i).String())
+			}
+		case jsonField:
+			b, err := json.Marshal(fv.Interface())
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %v", f.name, err)
+			}
+			e.str(string(b))
+		}
+	}
+	return nil
+}
+
+// WriteBinary encodes the graph in the compact binary format.
+func WriteBinary(w io.Writer, g Graph) error {
+	e := &encoder{strs: map[string]uint64{}}
+	gs, ps, ds := schema(graphType, "pkgs", "deps"), schema(pkgType), schema(depType, "pkg_id", "ups")
+	e.schema(gs)
+	e.schema(ps)
+	e.schema(ds)
+	if err := e.fields(reflect.ValueOf(g), gs); err != nil {
+		return err
+	}
+	e.length(reflect.ValueOf(g.Pkgs))
+	for _, p := range g.Pkgs {
+		if err := e.fields(reflect.ValueOf(p), ps); err != nil {
+			return err
+		}
+	}
+
+	ups := map[uint32][]Dependency{}
+	var sources []uint32
+	for s, deps := range g.Deps {
+		for _, d := range deps {
+			if d.Upstream {
+				ups[s] = append(ups[s], d)
+			}
+		}
+		if len(ups[s]) > 0 {
+			sources = append(sources, s)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i] < sources[j] })
+	if g.Deps == nil {
+		e.uvarint(0)
+	} else {
+		e.uvarint(uint64(len(sources)) + 1)
+	}
+	var prev uint32
+	for _, s := range sources {
+		e.uvarint(uint64(s - prev))
+		prev = s
+		deps := ups[s]
+		sort.Slice(deps, func(i, j int) bool { return deps[i].PkgID < deps[j].PkgID })
+		e.uvarint(uint64(len(deps)))
+		var pd uint32
+		for _, d := range deps {
+			e.uvarint(uint64(d.PkgID - pd))
+			pd = d.PkgID
+			if err := e.fields(reflect.ValueOf(d), ds); err != nil {
+				return err
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic)
+	bw.WriteByte(version)
+	n := binary.PutUvarint(e.buf[:], uint64(len(e.list)))
+	bw.Write(e.buf[:n])
+	for _, s := range e.list {
+		n = binary.PutUvarint(e.buf[:], uint64(len(s)))
+		bw.Write(e.buf[:n])
+		bw.WriteString(s)
+	}
+	if _, err := e.body.WriteTo(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type decoder struct {
+	r    *bufio.Reader
+	strs []string
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.fail(err)
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.fail(err)
+	return b
+}
+
+// length reads the length of a slice or map and whether it is nil.
+func (d *decoder) length() (uint64, bool) {
+	n := d.uvarint()
+	if n == 0 {
+		return 0, true
+	}
+	return n - 1, false
+}
+
+func (d *decoder) str() string {
+	i := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if i >= uint64(len(d.strs)) {
+		d.fail(errCorrupt)
+		return ""
+	}
+	return d.strs[i]
+}
+
+// schema reads a schema and maps its fields to the fields of the struct type t.
+func (d *decoder) schema(t reflect.Type) []field {
+	known := map[string]field{}
+	for _, f := range schema(t) {
+		known[f.name] = f
+	}
+	var fs []field
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		f := field{name: d.str(), typ: d.byte(), index: -1}
+		if k, ok := known[f.name]; ok && k.typ == f.typ {
+			f.index = k.index
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// fields reads the fields of a record into the struct v, the unknown fields are skipped.
+func (d *decoder) fields(v reflect.Value, fs []field) {
+	for _, f := range fs {
+		var fv reflect.Value
+		if f.index >= 0 {
+			fv = v.Field(f.index)
+		}
+		switch f.typ {
+		case stringField:
+			s := d.str()
+			if fv.IsValid() {
+				fv.SetString(s)
+			}
+		case boolField:
+			b := d.byte()
+			if fv.IsValid() {
+				fv.SetBool(b != 0)
+			}
+		case intField:
+			var i int64
+			if d.err == nil {
+				var err error
+				i, err = binary.ReadVarint(d.r)
+				d.fail(err)
+			}
+			if fv.IsValid() {
+				fv.SetInt(i)
+			}
+		case uintField:
+			u := d.uvarint()
+			if fv.IsValid() {
+				fv.SetUint(u)
+			}
+		case floatField:
+			var b [8]byte
+			if d.err == nil {
+				_, err := io.ReadFull(d.r, b[:])
+				d.fail(err)
+			}
+			if fv.IsValid() {
+				fv.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b[:])))
+			}
+		case listField:
+			n, isNil := d.length()
+			var l []string
+			if !isNil {
+				l = []string{}
+			}
+			for i := uint64(0); i < n && d.err == nil; i++ {
+				l = append(l, d.str())
+			}
+			if fv.IsValid() {
+				fv.Set(reflect.ValueOf(l))
+			}
+		case jsonField:
+			s := d.str()
+			if fv.IsValid() && d.err == nil {
+				if err := json.Unmarshal([]byte(s), fv.Addr().Interface()); err != nil {
+					d.fail(fmt.Errorf("failed to decode %s: %v", f.name, err))
+				}
+			}
+		default:
+			d.fail(errCorrupt)
+		}
+	}
+}
+
+// ReadBinary decodes a graph encoded in the binary format.
+func ReadBinary(r io.Reader) (Graph, error) {
+	var g Graph
+	d := &decoder{r: bufio.NewReader(r)}
+	h := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(d.r, h); err != nil {
+		return g, err
+	}
+	if string(h[:len(magic)]) != magic {
+		return g, errCorrupt
+	}
+	if h[len(magic)] != version {
+		return g, fmt.Errorf("unsupported binary dependency graph version: %d", h[len(magic)])
+	}
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		l := d.uvarint()
+		if l > 1<<30 {
+			d.fail(errCorrupt)
+			break
+		}
+		var b strings.Builder
+		_, err := io.CopyN(&b, d.r, int64(l))
+		d.fail(err)
+		d.strs = append(d.strs, b.String())
+	}
+	gs, ps, ds := d.schema(graphType), d.schema(pkgType), d.schema(depType)
+	d.fields(reflect.ValueOf(&g).Elem(), gs)
+
+	n, isNil := d.length()
+	if n > 0 && len(ps) == 0 {
+		// every field takes at least a byte, the pkgs without fields would not consume the input
+		d.fail(errCorrupt)
+	}
+	if !isNil {
+		g.Pkgs = []Pkg{}
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		var p Pkg
+		d.fields(reflect.ValueOf(&p).Elem(), ps)
+		g.Pkgs = append(g.Pkgs, p)
+	}
+
+	n, isNil = d.length()
+	if !isNil {
+		g.Deps = map[uint32][]Dependency{}
+	}
+	var s uint32
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		s += uint32(d.uvarint())
+		m := d.uvarint()
+		var t uint32
+		for j := uint64(0); j < m && d.err == nil; j++ {
+			t += uint32(d.uvarint())
+			dep := Dependency{PkgID: t, Upstream: true}
+			d.fields(reflect.ValueOf(&dep).Elem(), ds)
+			g.Deps[s] = append(g.Deps[s], dep)
+			dep.PkgID, dep.Upstream = s, false
+			g.Deps[t] = append(g.Deps[t], dep)
+		}
+	}
+	return g, d.err
+}
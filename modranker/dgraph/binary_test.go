@@ -0,0 +1,72 @@
+package dgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		g    Graph
+	}{
+		{"zero", Graph{}},
+		{"empty", Graph{Pkgs: []Pkg{}, Deps: map[uint32][]Dependency{}}},
+		{"lists", Graph{
+			Pkgs: []Pkg{
+				{ID: 1, ModuleName: "a", Topics: []string{}, Alternates: []string{"a/go.mod"}},
+				{ID: 2, ModuleName: "b", Rank: 0.5, PRank: 1},
+			},
+			Deps: map[uint32][]Dependency{
+				1: {{PkgID: 2, Upstream: true, Weight: 2, Kind: "test"}},
+				2: {{PkgID: 1, Weight: 2, Kind: "test"}},
+			},
+			Clusters: []Cluster{{ID: 1, Size: 2, Top: []string{}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteBinary(&b, tt.g); err != nil {
+				t.Fatal(err)
+			}
+			g, err := ReadBinary(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// The counts of a corrupt file don't allocate more than the input holds.
+func TestReadBinaryCorrupt(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"version", []byte(magic + "\x09")},
+		{"string length", append([]byte(magic+"\x01\x01"), 0xff, 0xff, 0xff, 0x03)},
+		{"schema field count", append([]byte(magic+"\x01\x00"), huge...)},
+		{"pkg count", []byte(magic + "\x01\x00\x00\x00\x00\xff\xff\xff\xff\x0f")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadBinary(bytes.NewReader(tt.data)); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package dgraph contains the module dependency graph computed by modranker
+// and reads and writes it either as JSON or in a compact binary encoding.
+package dgraph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+// Pkg is a ranked module.
+type Pkg struct {
+	ID          uint32   `json:"id"`
+	Name        string   `json:"name"`
+	ModuleName  string   `json:"module_name"`
+	RepoName    string   `json:"repo_name"`
+	Rank        float64  `json:"rank"`
+	PRank       int      `json:"prank"`
+	GRank       int      `json:"grank"` // 0-100 score, see the README
+	Tier        string   `json:"tier,omitempty"`
+	SRank       int      `json:"srank"`
+	Stars       int      `json:"stars"`
+	Imports     int      `json:"imports"`
+	Description string   `json:"description"`
+	Topics      []string `json:"topics"`
+	Seed        bool     `json:"seed,omitempty"`
+	Workspace   string   `json:"workspace,omitempty"`
+	Alternates  []string `json:"alternates,omitempty"`
+	Vulns       []Vuln   `json:"vulns,omitempty"`
+	// VulnerableReach is the rank share of the modules requiring a vulnerable version of the module.
+	VulnerableReach float64 `json:"vulnerable_reach,omitempty"`
+	License         string  `json:"license,omitempty"` // SPDX id
+	LicenseKind     string  `json:"license_kind,omitempty"`
+	// CopyleftDeps and UnlicensedDeps count the copyleft and the unlicensed modules among
+	// the transitive dependencies of the module.
+	CopyleftDeps   int `json:"copyleft_deps,omitempty"`
+	UnlicensedDeps int `json:"unlicensed_deps,omitempty"`
+	Cluster        int `json:"cluster,omitempty"` // id of the module's community, 0 when not clustered
+	// Alternatives are the modules used in similar contexts but rarely together with the module.
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+	// Unmaintained is set for the modules of archived or stale repositories.
+	Unmaintained bool `json:"unmaintained,omitempty"`
+	DirectDeps   int  `json:"direct_deps"`
+	// TransitiveDeps, Depth and UnmaintainedDeps take a traversal of the dependencies, they are
+	// only filled when requested by the health report or the table columns.
+	TransitiveDeps   int `json:"transitive_deps,omitempty"`
+	Depth            int `json:"depth,omitempty"` // of the dependency tree
+	UnmaintainedDeps int `json:"unmaintained_deps,omitempty"`
+	// DependentBusFactor is the least number of owners whose modules make up half of the
+	// dependents, 0 for the modules without dependents.
+	DependentBusFactor int `json:"dependent_bus_factor,omitempty"`
+}
+
+// Alternative is a module which can replace another one, Score is their similarity between 0 and 1.
+type Alternative struct {
+	Module string  `json:"module"`
+	GRank  int     `json:"grank"`
+	Score  float64 `json:"score"`
+}
+
+// Cluster summarizes a community of modules depending on each other.
+type Cluster struct {
+	ID   int     `json:"id"`
+	Size int     `json:"size"`
+	Rank float64 `json:"rank"` // share of the total rank
+	// Top are the best ranked modules and Topics the most frequent topics of the cluster.
+	Top    []string `json:"top"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+// Vuln is a known vulnerability affecting versions of a module required by its dependents.
+type Vuln struct {
+	ID                 string   `json:"id"`
+	Aliases            []string `json:"aliases,omitempty"`
+	AffectedDependents int      `json:"affected_dependents"`
+}
+
+// Dependency is an edge of the graph, Upstream is set when PkgID is a dependency of the module
+// the edge belongs to and unset when PkgID depends on it.
+type Dependency struct {
+	PkgID    uint32  `json:"pkg_id"`
+	Upstream bool    `json:"ups"`
+	Weight   float64 `json:"w,omitempty"`
+	Internal bool    `json:"internal,omitempty"`
+	Kind     string  `json:"kind,omitempty"`
+	Version  string  `json:"version,omitempty"`
+}
+
+// Graph is the dependency graph, the Pkgs are ordered by rank and every edge is stored
+// in the Deps of both of its ends.
+type Graph struct {
+	Pkgs     []Pkg                   `json:"pkgs"`
+	Deps     map[uint32][]Dependency `json:"deps"`
+	Clusters []Cluster               `json:"clusters,omitempty"`
+	// TopicRankings are the rankings of the modules of the most frequent topics.
+	TopicRankings []TopicRanking `json:"topic_rankings,omitempty"`
+	// Cycles are the strongly connected components of the graph having more than one module.
+	Cycles []Cycle `json:"cycles,omitempty"`
+}
+
+// Cycle is a set of modules depending on each other directly or transitively, Rank is their share of the total rank.
+type Cycle struct {
+	Modules []string `json:"modules"`
+	Rank    float64  `json:"rank"`
+}
+
+// TopicRanking ranks the modules having a topic, the Modules are in the order of the global ranking.
+type TopicRanking struct {
+	Topic   string      `json:"topic"`
+	Modules []TopicRank `json:"modules"`
+}
+
+// TopicRank is the rank of a module personalized for a topic and its position in the topic by it.
+type TopicRank struct {
+	ID    uint32  `json:"id"`
+	Rank  float64 `json:"rank"`
+	PRank int     `json:"prank"`
+}
+
+// DependsOn tells whether d is a dependency of s.
+func (g Graph) DependsOn(s, d uint32) bool {
+	for _, e := range g.Deps[s] {
+		if e.PkgID == d && e.Upstream {
+			return true
+		}
+	}
+	return false
+}
+
+// Read decodes a graph encoded as JSON or in the binary format, optionally gzip compressed.
+func Read(r io.Reader) (Graph, error) {
+	var g Graph
+	br := bufio.NewReader(r)
+	h, err := br.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return g, err
+	}
+	if bytes.HasPrefix(h, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return g, err
+		}
+		defer zr.Close()
+		return Read(zr)
+	}
+	if string(h) == magic {
+		return ReadBinary(br)
+	}
+	err = json.NewDecoder(br).Decode(&g)
+	return g, err
+}
+
+// WriteJSON encodes the graph as JSON.
+func WriteJSON(w io.Writer, g Graph) error {
+	return json.NewEncoder(w).Encode(g)
+}
+
+// ReadFile reads a graph file in any of the supported formats.
+func ReadFile(fn string) (Graph, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return Graph{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// WriteFile writes the graph in the format given by the extension of the file name:
+// .dgb for the binary format and JSON otherwise, a .gz suffix gzip compresses the file.
+func WriteFile(fn string, g Graph) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = write(f, strings.TrimSuffix(fn, ".gz"), strings.HasSuffix(fn, ".gz"), g)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func write(w io.Writer, fn string, compress bool, g Graph) error {
+	if compress {
+		zw := gzip.NewWriter(w)
+		if err := write(zw, fn, false, g); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	if strings.HasSuffix(fn, ".dgb") {
+		return WriteBinary(w, g)
+	}
+	return WriteJSON(w, g)
+}
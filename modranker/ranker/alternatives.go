@@ -0,0 +1,223 @@
+package ranker
+
+import (
+	"math"
+	"sort"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+const (
+	// minAlternativeScore is the similarity score above which a module is an alternative of another.
+	minAlternativeScore = 0.3
+	// profileKeys is the number of the strongest dependency profile entries used to find the
+	// alternative candidates of a module besides the modules sharing a topic with it.
+	profileKeys = 10
+	// maxTopicCandidates is the largest number of modules of a topic used as candidates, the
+	// generic topics like golang would make finding the candidates quadratic.
+	maxTopicCandidates = 200
+)
+
+// findAlternatives lists the k best ranked alternatives of every module with at least two dependents.
+//
+// Alternatives are used in similar contexts but rarely together, like logrus, zap and zerolog.
+// The context of a module is its dependency profile: how many of its dependents depend on each
+// other module, weighted by the inverse frequency of the other module's dependents, so that the
+// ubiquitous dependencies do not make everything similar. The similarity of two modules is the
+// mean of the cosine similarity of their profiles and the Jaccard similarity of their topics
+// weighted by the inverse frequency of the topics, scaled down by the share of the dependents
+// of the less used module depending on both. The topics only count for modules with similar
+// profiles, sharing a topic alone doesn't make two modules alternatives.
+func findAlternatives(dg dgraph.Graph, k int) {
+	idx := make(map[uint32]int, len(dg.Pkgs))
+	for i, p := range dg.Pkgs {
+		idx[p.ID] = i
+	}
+	n := len(dg.Pkgs)
+	dependents := make([][]int, n)
+	deps := make([][]int, n)
+	for i, p := range dg.Pkgs {
+		for _, d := range dg.Deps[p.ID] {
+			j, ok := idx[d.PkgID]
+			if !ok {
+				continue
+			}
+			if d.Upstream {
+				deps[i] = append(deps[i], j)
+			} else {
+				dependents[i] = append(dependents[i], j)
+			}
+		}
+	}
+
+	profiles := make([]map[int]float64, n)
+	norms := make([]float64, n)
+	for i := range dg.Pkgs {
+		if len(dependents[i]) < 2 {
+			continue
+		}
+		profiles[i] = map[int]float64{}
+		for _, d := range dependents[i] {
+			for _, x := range deps[d] {
+				if x != i {
+					profiles[i][x]++
+				}
+			}
+		}
+		for x, v := range profiles[i] {
+			profiles[i][x] = v * math.Log(1+float64(n)/float64(len(dependents[x])))
+			norms[i] += profiles[i][x] * profiles[i][x]
+		}
+	}
+
+	topics := map[string]int{}
+	var profiled int
+	for i, p := range dg.Pkgs {
+		if profiles[i] == nil {
+			continue
+		}
+		profiled++
+		for _, t := range p.Topics {
+			topics[t]++
+		}
+	}
+	idf := make(map[string]float64, len(topics))
+	for t, c := range topics {
+		idf[t] = math.Log(float64(profiled) / float64(c))
+	}
+
+	candidates := map[string][]int{}
+	for i, p := range dg.Pkgs {
+		if profiles[i] == nil {
+			continue
+		}
+		for _, t := range p.Topics {
+			if topics[t] <= maxTopicCandidates {
+				candidates["topic:"+t] = append(candidates["topic:"+t], i)
+			}
+		}
+		for _, x := range strongest(profiles[i], profileKeys) {
+			candidates["profile:"+dg.Pkgs[x].ModuleName] = append(candidates["profile:"+dg.Pkgs[x].ModuleName], i)
+		}
+	}
+
+	for i, p := range dg.Pkgs {
+		if profiles[i] == nil {
+			continue
+		}
+		keys := make([]string, 0, len(p.Topics)+profileKeys)
+		for _, t := range p.Topics {
+			if topics[t] <= maxTopicCandidates {
+				keys = append(keys, "topic:"+t)
+			}
+		}
+		for _, x := range strongest(profiles[i], profileKeys) {
+			keys = append(keys, "profile:"+dg.Pkgs[x].ModuleName)
+		}
+		seen := map[int]bool{i: true}
+		var alts []dgraph.Alternative
+		for _, key := range keys {
+			for _, j := range candidates[key] {
+				if seen[j] {
+					continue
+				}
+				seen[j] = true
+				s := profileSimilarity(profiles, norms, i, j)
+				if s == 0 {
+					continue
+				}
+				s = (s + weightedJaccard(p.Topics, dg.Pkgs[j].Topics, idf)) / 2
+				s *= 1 - coUse(dependents[i], dependents[j])
+				if s >= minAlternativeScore {
+					alts = append(alts, dgraph.Alternative{Module: dg.Pkgs[j].ModuleName, GRank: dg.Pkgs[j].GRank, Score: s})
+				}
+			}
+		}
+		sort.Slice(alts, func(a, b int) bool {
+			if alts[a].GRank != alts[b].GRank {
+				return alts[a].GRank > alts[b].GRank
+			}
+			return alts[a].Score > alts[b].Score
+		})
+		if len(alts) > k {
+			alts = alts[:k]
+		}
+		dg.Pkgs[i].Alternatives = alts
+	}
+}
+
+// strongest returns the keys of the k highest entries of the profile.
+func strongest(profile map[int]float64, k int) []int {
+	keys := make([]int, 0, len(profile))
+	for x := range profile {
+		keys = append(keys, x)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if profile[keys[a]] != profile[keys[b]] {
+			return profile[keys[a]] > profile[keys[b]]
+		}
+		return keys[a] < keys[b]
+	})
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+	return keys
+}
+
+// profileSimilarity is the cosine similarity of the profiles of i and j without their entries
+// of i and j, a module is often used together with the alternatives of its own dependents.
+func profileSimilarity(profiles []map[int]float64, norms []float64, i, j int) float64 {
+	pi, pj := profiles[i], profiles[j]
+	ni, nj := norms[i]-pi[j]*pi[j], norms[j]-pj[i]*pj[i]
+	if ni <= 0 || nj <= 0 {
+		return 0
+	}
+	if len(pj) < len(pi) {
+		pi, pj = pj, pi
+	}
+	var dot float64
+	for x, v := range pi {
+		if x != i && x != j {
+			dot += v * pj[x]
+		}
+	}
+	return dot / math.Sqrt(ni*nj)
+}
+
+// weightedJaccard is the Jaccard similarity of the topics, every topic counting with its weight.
+func weightedJaccard(a, b []string, weights map[string]float64) float64 {
+	var common, union float64
+	for _, e := range a {
+		union += weights[e]
+		if contains(b, e) {
+			common += weights[e]
+		}
+	}
+	for _, e := range b {
+		if !contains(a, e) {
+			union += weights[e]
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return common / union
+}
+
+// coUse returns the share of the dependents of the less used module which depend on both modules.
+func coUse(a, b []int) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	in := make(map[int]bool, len(b))
+	for _, e := range b {
+		in[e] = true
+	}
+	var common int
+	for _, e := range a {
+		if in[e] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a))
+}
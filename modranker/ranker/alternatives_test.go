@@ -0,0 +1,65 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestFindAlternatives(t *testing.T) {
+	// the apps 1-4 use the loggers 10 or 11 with the common dependency 20,
+	// the apps 5 and 6 use the unrelated 12 with 21, every module has the golang topic
+	const (
+		loggerA, loggerB, unrelated = 10, 11, 12
+	)
+	dg := testGraph(deps(
+		1, loggerA, 2, loggerA, 3, loggerB, 4, loggerB,
+		1, 20, 2, 20, 3, 20, 4, 20,
+		5, unrelated, 6, unrelated, 5, 21, 6, 21,
+	))
+	topics := map[uint32][]string{
+		loggerA:   {"golang", "logging"},
+		loggerB:   {"golang", "logging"},
+		unrelated: {"golang", "orm"},
+	}
+	for id := range dg.Deps {
+		ts, ok := topics[id]
+		if !ok {
+			ts = []string{"golang"}
+		}
+		dg.Pkgs = append(dg.Pkgs, dgraph.Pkg{ID: id, ModuleName: string(rune('a' + id)), Topics: ts})
+	}
+	findAlternatives(dg, 5)
+	alts := map[uint32][]string{}
+	for _, p := range dg.Pkgs {
+		for _, a := range p.Alternatives {
+			alts[p.ID] = append(alts[p.ID], a.Module)
+		}
+	}
+	name := func(id uint32) string { return string(rune('a' + id)) }
+	if len(alts[loggerA]) != 1 || alts[loggerA][0] != name(loggerB) {
+		t.Errorf("alternatives of logger A: got %v, want logger B", alts[loggerA])
+	}
+	if len(alts[unrelated]) != 0 {
+		t.Errorf("alternatives of the unrelated module: got %v, want none", alts[unrelated])
+	}
+}
+
+func TestWeightedJaccard(t *testing.T) {
+	weights := map[string]float64{"golang": 0, "logging": 2, "orm": 2, "cli": 1}
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{nil, []string{"cli"}, 0},
+		{[]string{"golang"}, []string{"golang"}, 0},
+		{[]string{"golang", "orm"}, []string{"golang", "logging"}, 0},
+		{[]string{"golang", "logging"}, []string{"golang", "logging"}, 1},
+		{[]string{"logging", "cli"}, []string{"logging"}, 2.0 / 3},
+	}
+	for _, tt := range tests {
+		if got := weightedJaccard(tt.a, tt.b, weights); got != tt.want {
+			t.Errorf("weightedJaccard(%v, %v) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
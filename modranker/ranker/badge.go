@@ -0,0 +1,122 @@
+package ranker
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+const badgeLabel = "grank"
+
+var tierColors = map[string]string{
+	"top 0.1%": "#4c1",
+	"top 1%":   "#97ca00",
+	"top 10%":  "#a4a61d",
+}
+
+// badgeText returns the value shown on the badge of the module, e.g. "#123 / top 1%".
+// The seeds of a personalized ranking have no position.
+func badgeText(p dgraph.Pkg) string {
+	if p.Seed {
+		return "seed"
+	}
+	t := fmt.Sprintf("#%d", p.PRank)
+	if p.Tier != "" {
+		t += " / " + p.Tier
+	}
+	return t
+}
+
+// textWidth approximates the width of the text rendered in 11px Verdana.
+func textWidth(s string) int {
+	return 7*len(s) + 10
+}
+
+// writeBadge writes a shields.io style flat SVG badge of the module's rank.
+func writeBadge(w io.Writer, p dgraph.Pkg) error {
+	value := badgeText(p)
+	color, ok := tierColors[p.Tier]
+	if !ok {
+		color = "#007ec6"
+	}
+	lw, vw := textWidth(badgeLabel), textWidth(value)
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s: %[3]s">
+<title>%[2]s: %[3]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[4]d" height="20" fill="#555"/><rect x="%[4]d" width="%[5]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[2]s</text><text x="%[7]d" y="14">%[2]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[3]s</text><text x="%[8]d" y="14">%[3]s</text>
+</g>
+</svg>
+`, lw+vw, badgeLabel, html.EscapeString(value), lw, vw, color, lw/2, lw+vw/2)
+	return err
+}
+
+// badgePath returns the path of the module's badge in dir, empty for module paths which are unsafe as file paths.
+func badgePath(dir, module string) string {
+	for _, e := range strings.Split(module, "/") {
+		if e == "" || e == "." || e == ".." {
+			return ""
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(module)) + ".svg"
+}
+
+func badgesCmd(args []string) {
+	fl := flag.NewFlagSet("badges", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	dir := fl.String("o", "badges", "output directory, the badge of a module is written to DIR/MODULE_PATH.svg")
+	fl.Parse(args)
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	for _, p := range dg.Pkgs {
+		fn := badgePath(*dir, p.ModuleName)
+		if fn == "" {
+			log.Printf("skipping badge of %s", p.ModuleName)
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(fn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = writeBadge(f, p)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Fatalf("failed to write %s: %v", fn, err)
+		}
+	}
+	log.Printf("%d badges written to %s", len(dg.Pkgs), *dir)
+}
+
+// badgeHandler serves the badges at /badge/MODULE_PATH.svg
+func (gi *graphIndex) badgeHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/badge/"), ".svg")
+	p, ok := gi.pkg(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	if err := writeBadge(w, p); err != nil {
+		log.Printf("failed to write badge: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestBadgeText(t *testing.T) {
+	tests := []struct {
+		p    dgraph.Pkg
+		want string
+	}{
+		{dgraph.Pkg{PRank: 123}, "#123"},
+		{dgraph.Pkg{PRank: 5, Tier: "top 1%"}, "#5 / top 1%"},
+		{dgraph.Pkg{Seed: true}, "seed"},
+	}
+	for _, tt := range tests {
+		if got := badgeText(tt.p); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
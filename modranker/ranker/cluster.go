@@ -0,0 +1,196 @@
+package ranker
+
+import (
+	"sort"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// clusterTop is the number of modules and topics listed in the cluster summaries.
+const clusterTop = 5
+
+type edge struct {
+	to int
+	w  float64
+}
+
+// louvain partitions the undirected graph given by its adjacency lists, self loops included,
+// into communities by greedily maximizing the modularity, see Blondel et al., Fast unfolding
+// of communities in large networks. The adjacency lists must be ordered by node to keep the
+// result deterministic. It returns the community of every node.
+func louvain(adj [][]edge) []int {
+	comm := make([]int, len(adj))
+	for i := range comm {
+		comm[i] = i
+	}
+	for {
+		level, moved := louvainLevel(adj)
+		if !moved {
+			return comm
+		}
+		// renumber the communities and aggregate them into the nodes of the next level
+		ids := map[int]int{}
+		for _, c := range level {
+			if _, ok := ids[c]; !ok {
+				ids[c] = len(ids)
+			}
+		}
+		for i, c := range comm {
+			comm[i] = ids[level[c]]
+		}
+		agg := make([]map[int]float64, len(ids))
+		for i := range agg {
+			agg[i] = map[int]float64{}
+		}
+		for i, es := range adj {
+			for _, e := range es {
+				agg[ids[level[i]]][ids[level[e.to]]] += e.w
+			}
+		}
+		adj = make([][]edge, len(agg))
+		for i, es := range agg {
+			for j, w := range es {
+				adj[i] = append(adj[i], edge{j, w})
+			}
+			sort.Slice(adj[i], func(a, b int) bool { return adj[i][a].to < adj[i][b].to })
+		}
+	}
+}
+
+// louvainLevel moves the nodes to the neighbouring community with the highest modularity gain
+// until no move improves it, it returns the communities and whether any node was moved.
+func louvainLevel(adj [][]edge) ([]int, bool) {
+	n := len(adj)
+	comm := make([]int, n)
+	k := make([]float64, n)
+	tot := make([]float64, n)
+	var m2 float64
+	for i, es := range adj {
+		comm[i] = i
+		for _, e := range es {
+			k[i] += e.w
+		}
+		tot[i] = k[i]
+		m2 += k[i]
+	}
+	if m2 == 0 {
+		return comm, false
+	}
+	moved := false
+	in := map[int]float64{}
+	for improved := true; improved; {
+		improved = false
+		for i, es := range adj {
+			for c := range in {
+				delete(in, c)
+			}
+			var order []int
+			for _, e := range es {
+				if e.to == i {
+					continue
+				}
+				c := comm[e.to]
+				if _, ok := in[c]; !ok {
+					order = append(order, c)
+				}
+				in[c] += e.w
+			}
+			old := comm[i]
+			tot[old] -= k[i]
+			best, gain := old, in[old]-tot[old]*k[i]/m2
+			for _, c := range order {
+				if g := in[c] - tot[c]*k[i]/m2; g > gain {
+					best, gain = c, g
+				}
+			}
+			tot[best] += k[i]
+			if best != old {
+				comm[i] = best
+				improved, moved = true, true
+			}
+		}
+	}
+	return comm, moved
+}
+
+// detectClusters tags the modules with the id of their community in the undirected, unweighted
+// dependency graph and returns the summaries of the clusters. The communities are detected by
+// structure alone: the edge weights reflect the popularity of the dependents, not how related
+// the modules are. Communities smaller than minSize are left unclustered (id 0), the others
+// are numbered from 1 in the decreasing order of their total rank.
+func detectClusters(dg dgraph.Graph, minSize int) []dgraph.Cluster {
+	idx := make(map[uint32]int, len(dg.Pkgs))
+	for i, p := range dg.Pkgs {
+		idx[p.ID] = i
+	}
+	adj := make([][]edge, len(dg.Pkgs))
+	for i, p := range dg.Pkgs {
+		seen := map[int]bool{}
+		for _, d := range dg.Deps[p.ID] {
+			j, ok := idx[d.PkgID]
+			if ok && j != i && !seen[j] {
+				seen[j] = true
+				adj[i] = append(adj[i], edge{j, 1})
+			}
+		}
+		sort.Slice(adj[i], func(a, b int) bool { return adj[i][a].to < adj[i][b].to })
+	}
+	comm := louvain(adj)
+
+	members := map[int][]int{}
+	rank := map[int]float64{}
+	var total float64
+	for i, c := range comm {
+		members[c] = append(members[c], i)
+		rank[c] += dg.Pkgs[i].Rank
+		total += dg.Pkgs[i].Rank
+	}
+	var cs []int
+	for c, ms := range members {
+		if len(ms) >= minSize {
+			cs = append(cs, c)
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if rank[cs[i]] != rank[cs[j]] {
+			return rank[cs[i]] > rank[cs[j]]
+		}
+		return members[cs[i]][0] < members[cs[j]][0]
+	})
+	for i := range dg.Pkgs {
+		dg.Pkgs[i].Cluster = 0
+	}
+	summaries := make([]dgraph.Cluster, 0, len(cs))
+	for n, c := range cs {
+		s := dgraph.Cluster{ID: n + 1, Size: len(members[c])}
+		if total > 0 {
+			s.Rank = rank[c] / total
+		}
+		topics := map[string]int{}
+		// the members are in rank order as dg.Pkgs
+		for _, i := range members[c] {
+			dg.Pkgs[i].Cluster = s.ID
+			if len(s.Top) < clusterTop {
+				s.Top = append(s.Top, dg.Pkgs[i].ModuleName)
+			}
+			for _, t := range dg.Pkgs[i].Topics {
+				topics[t]++
+			}
+		}
+		for t := range topics {
+			s.Topics = append(s.Topics, t)
+		}
+		sort.Slice(s.Topics, func(i, j int) bool {
+			ti, tj := s.Topics[i], s.Topics[j]
+			if topics[ti] != topics[tj] {
+				return topics[ti] > topics[tj]
+			}
+			return ti < tj
+		})
+		if len(s.Topics) > clusterTop {
+			s.Topics = s.Topics[:clusterTop]
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
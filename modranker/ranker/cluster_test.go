@@ -0,0 +1,54 @@
+package ranker
+
+import (
+	"testing"
+)
+
+// undirected returns the adjacency lists of the undirected graph with the given edges.
+func undirected(n int, pairs ...int) [][]edge {
+	adj := make([][]edge, n)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		a, b := pairs[i], pairs[i+1]
+		adj[a] = append(adj[a], edge{b, 1})
+		adj[b] = append(adj[b], edge{a, 1})
+	}
+	return adj
+}
+
+func TestLouvain(t *testing.T) {
+	tests := []struct {
+		name string
+		adj  [][]edge
+		want [][]int // the communities, as node lists
+	}{
+		{"no edges", undirected(3), [][]int{{0}, {1}, {2}}},
+		{"two pairs", undirected(4, 0, 1, 2, 3), [][]int{{0, 1}, {2, 3}}},
+		{"bridged triangles", undirected(6, 0, 1, 1, 2, 0, 2, 3, 4, 4, 5, 3, 5, 2, 3), [][]int{{0, 1, 2}, {3, 4, 5}}},
+		{"bridged cliques", undirected(8,
+			0, 1, 0, 2, 0, 3, 1, 2, 1, 3, 2, 3,
+			4, 5, 4, 6, 4, 7, 5, 6, 5, 7, 6, 7,
+			0, 4), [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comm := louvain(tt.adj)
+			if len(comm) != len(tt.adj) {
+				t.Fatalf("got %d communities for %d nodes", len(comm), len(tt.adj))
+			}
+			of := map[int]int{}
+			for c, ns := range tt.want {
+				for _, n := range ns {
+					of[n] = c
+				}
+			}
+			// the ids of the communities are arbitrary, only the pairs in the same community are compared
+			for i := range comm {
+				for j := range comm {
+					if (comm[i] == comm[j]) != (of[i] == of[j]) {
+						t.Errorf("nodes %d and %d: got communities %d and %d, want %v", i, j, comm[i], comm[j], tt.want)
+					}
+				}
+			}
+		})
+	}
+}
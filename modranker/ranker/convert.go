@@ -0,0 +1,23 @@
+package ranker
+
+import (
+	"flag"
+	"log"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func convertCmd(args []string) {
+	fl := flag.NewFlagSet("convert", flag.ExitOnError)
+	in := fl.String("i", "dg.json", "input dependency graph file, JSON or binary, optionally gzip compressed")
+	out := fl.String("o", "dg.dgb", "output dependency graph file, a .dgb extension selects the binary format and .gz compresses it")
+	fl.Parse(args)
+
+	dg, err := dgraph.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", *in, err)
+	}
+	if err = dgraph.WriteFile(*out, dg); err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+}
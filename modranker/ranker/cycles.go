@@ -0,0 +1,264 @@
+package ranker
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/alixaxel/pagerank"
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// cycles returns the strongly connected components of the dependency graph having more than one
+// module, found by Tarjan's algorithm. The modules of a component are ordered by id and the
+// components by decreasing size.
+func cycles(dg dgraph.Graph) [][]uint32 {
+	index := map[uint32]int{}
+	low := map[uint32]int{}
+	onStack := map[uint32]bool{}
+	var stack []uint32
+	var sccs [][]uint32
+	type frame struct {
+		id   uint32
+		next int // index of the next edge to visit
+	}
+	for _, root := range sortedIDs(dg) {
+		if _, ok := index[root]; ok {
+			continue
+		}
+		// iterative depth first search, the graphs are deep enough to make recursion risky
+		calls := []frame{{id: root}}
+		index[root], low[root] = len(index), len(index)
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			deps := dg.Deps[f.id]
+			if f.next < len(deps) {
+				d := deps[f.next]
+				f.next++
+				if !d.Upstream {
+					continue
+				}
+				if _, ok := index[d.PkgID]; !ok {
+					index[d.PkgID], low[d.PkgID] = len(index), len(index)
+					stack = append(stack, d.PkgID)
+					onStack[d.PkgID] = true
+					calls = append(calls, frame{id: d.PkgID})
+				} else if onStack[d.PkgID] && index[d.PkgID] < low[f.id] {
+					low[f.id] = index[d.PkgID]
+				}
+				continue
+			}
+			id := f.id
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				if p := calls[len(calls)-1].id; low[id] < low[p] {
+					low[p] = low[id]
+				}
+			}
+			if low[id] != index[id] {
+				continue
+			}
+			var scc []uint32
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				scc = append(scc, m)
+				if m == id {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				sort.Slice(scc, func(i, j int) bool { return scc[i] < scc[j] })
+				sccs = append(sccs, scc)
+			}
+		}
+	}
+	sort.SliceStable(sccs, func(i, j int) bool { return len(sccs[i]) > len(sccs[j]) })
+	return sccs
+}
+
+// sortedIDs returns the ids of the modules of dg in increasing order, to visit them deterministically.
+func sortedIDs(dg dgraph.Graph) []uint32 {
+	ids := make([]uint32, 0, len(dg.Deps))
+	for id := range dg.Deps {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// collapseCycles returns the dependency graph with the modules of every component merged into
+// their first module, a super-node, and the super-node of every merged module. The edges within
+// a component are dropped, the parallel edges of the super-nodes are merged summing their weights.
+func collapseCycles(dg dgraph.Graph, sccs [][]uint32) (dgraph.Graph, map[uint32]uint32) {
+	rep := map[uint32]uint32{}
+	for _, scc := range sccs {
+		for _, m := range scc {
+			rep[m] = scc[0]
+		}
+	}
+	node := func(id uint32) uint32 {
+		if r, ok := rep[id]; ok {
+			return r
+		}
+		return id
+	}
+	cg := dgraph.Graph{Deps: make(map[uint32][]dgraph.Dependency, len(dg.Deps))}
+	pos := map[[2]uint32]int{}
+	for _, s := range sortedIDs(dg) {
+		cs := node(s)
+		if _, ok := cg.Deps[cs]; !ok {
+			cg.Deps[cs] = nil
+		}
+		for _, d := range dg.Deps[s] {
+			cd := node(d.PkgID)
+			if cd == cs || !d.Upstream {
+				continue
+			}
+			if i, ok := pos[[2]uint32{cs, cd}]; ok {
+				cg.Deps[cs][i].Weight += d.Weight
+				continue
+			}
+			pos[[2]uint32{cs, cd}] = len(cg.Deps[cs])
+			cg.Deps[cs] = append(cg.Deps[cs], dgraph.Dependency{PkgID: cd, Upstream: true, Weight: d.Weight})
+		}
+	}
+	// the downstream edges mirror the merged upstream ones
+	for _, s := range sortedIDs(cg) {
+		for _, d := range cg.Deps[s] {
+			if d.Upstream {
+				cg.Deps[d.PkgID] = append(cg.Deps[d.PkgID], dgraph.Dependency{PkgID: s, Weight: d.Weight})
+			}
+		}
+	}
+	return cg, rep
+}
+
+// linkGraph returns the pagerank graph of the upstream edges of dg, linked in the order of the ids.
+// The modules without edges, e.g. the super-node of a collapsed cycle nothing outside of it depends
+// on and which depends on nothing outside, are added by a self loop of 0 weight: pagerank.Graph
+// only knows the linked nodes and it ranks a node without outbound weight like a module without dependencies.
+func linkGraph(dg dgraph.Graph) *pagerank.Graph {
+	g := pagerank.NewGraph()
+	for _, s := range sortedIDs(dg) {
+		if len(dg.Deps[s]) == 0 {
+			g.Link(s, s, 0)
+		}
+		for _, d := range dg.Deps[s] {
+			if d.Upstream {
+				g.Link(s, d.PkgID, d.Weight)
+			}
+		}
+	}
+	return g
+}
+
+// expandCycles wraps the callback of the ranking of the collapsed graph: the rank of a super-node
+// is split among the modules of its component in proportion to the weight of their dependents
+// outside of the component, or equally when they have none.
+func expandCycles(dg dgraph.Graph, sccs [][]uint32, callback func(id uint32, rank float64)) func(id uint32, rank float64) {
+	members := map[uint32][]uint32{}
+	shares := map[uint32]float64{}
+	for _, scc := range sccs {
+		members[scc[0]] = scc
+		in := map[uint32]bool{}
+		for _, m := range scc {
+			in[m] = true
+		}
+		var total float64
+		for _, m := range scc {
+			for _, d := range dg.Deps[m] {
+				if !d.Upstream && !in[d.PkgID] {
+					shares[m] += d.Weight
+				}
+			}
+			total += shares[m]
+		}
+		for _, m := range scc {
+			if total > 0 {
+				shares[m] /= total
+			} else {
+				shares[m] = 1 / float64(len(scc))
+			}
+		}
+	}
+	return func(id uint32, rank float64) {
+		ms, ok := members[id]
+		if !ok {
+			callback(id, rank)
+			return
+		}
+		for _, m := range ms {
+			callback(m, rank*shares[m])
+		}
+	}
+}
+
+// summarizeCycles returns the components with their modules in rank order and the rank share
+// they hold, in the decreasing order of the rank share.
+func summarizeCycles(dg dgraph.Graph, sccs [][]uint32) []dgraph.Cycle {
+	idx := make(map[uint32]int, len(dg.Pkgs))
+	var total float64
+	for i, p := range dg.Pkgs {
+		idx[p.ID] = i
+		total += p.Rank
+	}
+	var cs []dgraph.Cycle
+	for _, scc := range sccs {
+		ms := make([]int, len(scc))
+		for i, id := range scc {
+			ms[i] = idx[id]
+		}
+		sort.Ints(ms)
+		c := dgraph.Cycle{}
+		for _, i := range ms {
+			c.Modules = append(c.Modules, dg.Pkgs[i].ModuleName)
+			c.Rank += dg.Pkgs[i].Rank
+		}
+		if total > 0 {
+			c.Rank /= total
+		}
+		cs = append(cs, c)
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Rank > cs[j].Rank })
+	return cs
+}
+
+// cycleReportColumns are the columns of the cycle report, a row per module of the cycles.
+var cycleReportColumns = []string{"cycle", "modules", "rank_share", "module_name"}
+
+// writeCycleReport writes the cycles in the decreasing order of their rank share to fn.
+func writeCycleReport(fn, format string, cs []dgraph.Cycle) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = writeCycles(f, format, cs)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeCycles(w io.Writer, format string, cs []dgraph.Cycle) error {
+	tw, err := newTableWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err = tw.Write(cycleReportColumns); err != nil {
+		return err
+	}
+	for i, c := range cs {
+		for _, m := range c.Modules {
+			row := []string{strconv.Itoa(i + 1), strconv.Itoa(len(c.Modules)), strconv.FormatFloat(c.Rank, 'g', -1, 64), m}
+			if err = tw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,70 @@
+package ranker
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// deps returns the upstream edges of the pairs s1, d1, s2, d2... for testGraph.
+func deps(pairs ...uint32) map[uint32][]dgraph.Dependency {
+	edges := map[uint32][]dgraph.Dependency{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		edges[pairs[i]] = append(edges[pairs[i]], dgraph.Dependency{PkgID: pairs[i+1], Weight: 1})
+	}
+	return edges
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges map[uint32][]dgraph.Dependency
+		want  [][]uint32
+	}{
+		{"acyclic", deps(1, 2, 2, 3, 1, 3), nil},
+		{"mutual", deps(1, 2, 2, 1, 2, 3), [][]uint32{{1, 2}}},
+		{"ring", deps(3, 1, 1, 2, 2, 3, 3, 4), [][]uint32{{1, 2, 3}}},
+		{"two cycles", deps(1, 2, 2, 1, 3, 4, 4, 5, 5, 3, 2, 3), [][]uint32{{3, 4, 5}, {1, 2}}},
+		{"nested", deps(1, 2, 2, 3, 3, 1, 2, 4, 4, 2), [][]uint32{{1, 2, 3, 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cycles(testGraph(tt.edges)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A collapsed cycle without outside edges is ranked too.
+func TestCollapsedIsolatedCycle(t *testing.T) {
+	dg := testGraph(deps(1, 2, 2, 1, 3, 4))
+	sccs := cycles(dg)
+	cg, _ := collapseCycles(dg, sccs)
+	ranks := map[uint32]float64{}
+	linkGraph(cg).Rank(0.85, 1e-9, expandCycles(dg, sccs, func(id uint32, r float64) {
+		ranks[id] = r
+	}))
+	for _, id := range []uint32{1, 2, 3, 4} {
+		if ranks[id] <= 0 {
+			t.Errorf("rank of %d is %g, want > 0", id, ranks[id])
+		}
+	}
+	if ranks[1] != ranks[2] {
+		t.Errorf("the modules of the cycle got %g and %g, want an equal split", ranks[1], ranks[2])
+	}
+}
+
+func TestWriteCycles(t *testing.T) {
+	cs := []dgraph.Cycle{{Modules: []string{"a", "b"}, Rank: 0.5}, {Modules: []string{"c", "d", "e"}, Rank: 0.25}}
+	var b bytes.Buffer
+	if err := writeCycles(&b, "csv", cs); err != nil {
+		t.Fatal(err)
+	}
+	want := "cycle,modules,rank_share,module_name\n1,2,0.5,a\n1,2,0.5,b\n2,3,0.25,c\n2,3,0.25,d\n2,3,0.25,e\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,159 @@
+package ranker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+type dependent struct {
+	Module string `json:"module"`
+	Depth  int    `json:"depth"`
+	Stars  int    `json:"stars"`
+	// Requires is the module the dependent requires on its shortest path to the queried module
+	// and Version is the required version of it.
+	Requires string `json:"requires"`
+	Version  string `json:"version,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+
+	id, parent uint32
+}
+
+// dependents returns the direct and transitive dependents of the module with the id in breadth first
+// order, up to maxDepth levels deep (unlimited when maxDepth <= 0).
+func dependents(dg dgraph.Graph, id uint32, maxDepth int) []dependent {
+	pkgs := make(map[uint32]dgraph.Pkg, len(dg.Pkgs))
+	for _, p := range dg.Pkgs {
+		pkgs[p.ID] = p
+	}
+	var res []dependent
+	seen := map[uint32]bool{id: true}
+	level := []uint32{id}
+	for depth := 1; len(level) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var next []dependent
+		for _, c := range level {
+			for _, d := range dg.Deps[c] {
+				if d.Upstream || seen[d.PkgID] {
+					continue
+				}
+				seen[d.PkgID] = true
+				p := pkgs[d.PkgID]
+				next = append(next, dependent{
+					Module:   p.ModuleName,
+					Depth:    depth,
+					Stars:    p.Stars,
+					Requires: pkgs[c].ModuleName,
+					Version:  d.Version,
+					Kind:     d.Kind,
+					id:       d.PkgID,
+					parent:   c,
+				})
+			}
+		}
+		sort.SliceStable(next, func(i, j int) bool { return next[i].Stars > next[j].Stars })
+		level = level[:0]
+		for _, d := range next {
+			level = append(level, d.id)
+		}
+		res = append(res, next...)
+	}
+	return res
+}
+
+func writeDependentsList(w io.Writer, ds []dependent) error {
+	tw, err := newTableWriter(w, "tsv")
+	if err != nil {
+		return err
+	}
+	if err = tw.Write([]string{"depth", "module", "stars", "requires", "version", "kind"}); err != nil {
+		return err
+	}
+	for _, d := range ds {
+		if err = tw.Write([]string{fmt.Sprint(d.Depth), d.Module, fmt.Sprint(d.Stars), d.Requires, d.Version, d.Kind}); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// writeDependentsTree prints every dependent under the module it requires on its shortest path.
+func writeDependentsTree(w io.Writer, root string, rootID uint32, ds []dependent) error {
+	children := map[uint32][]dependent{}
+	for _, d := range ds {
+		children[d.parent] = append(children[d.parent], d)
+	}
+	if _, err := fmt.Fprintln(w, root); err != nil {
+		return err
+	}
+	var walk func(id uint32, indent string) error
+	walk = func(id uint32, indent string) error {
+		cs := children[id]
+		for i, d := range cs {
+			branch, sub := "├── ", "│   "
+			if i == len(cs)-1 {
+				branch, sub = "└── ", "    "
+			}
+			v := ""
+			if d.Version != "" {
+				v = "@" + d.Version
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s (%d stars, requires %s%s)\n", indent, branch, d.Module, d.Stars, d.Requires, v); err != nil {
+				return err
+			}
+			if err := walk(d.id, indent+sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(rootID, "")
+}
+
+func dependentsCmd(args []string) {
+	fl := flag.NewFlagSet("dependents", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	maxDepth := fl.Int("depth", 0, "maximum depth of the transitive dependents, 0 means unlimited")
+	format := fl.String("f", "list", "output format: tree, list or json")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: modranker dependents [flags] module")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	if fl.NArg() != 1 {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	p, ok := newGraphIndex(dg).pkg(fl.Arg(0))
+	if !ok {
+		log.Fatalf("module %s is not in %s", fl.Arg(0), *gf)
+	}
+	ds := dependents(dg, p.ID, *maxDepth)
+	switch strings.ToLower(*format) {
+	case "json":
+		if ds == nil {
+			ds = []dependent{}
+		}
+		err = json.NewEncoder(os.Stdout).Encode(ds)
+	case "list":
+		err = writeDependentsList(os.Stdout, ds)
+	case "tree":
+		err = writeDependentsTree(os.Stdout, p.ModuleName, p.ID, ds)
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
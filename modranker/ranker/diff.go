@@ -0,0 +1,206 @@
+package ranker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+type movement struct {
+	Module  string `json:"module"`
+	OldRank int    `json:"old_rank,omitempty"`
+	NewRank int    `json:"new_rank,omitempty"`
+	// Change is positive when the module moved up in the ranking.
+	Change int `json:"change"`
+}
+
+type rankDiff struct {
+	Old      string     `json:"old"`
+	New      string     `json:"new"`
+	Top      int        `json:"top"`
+	Added    int        `json:"added"`
+	Removed  int        `json:"removed"`
+	Entrants []movement `json:"entrants"`
+	Dropouts []movement `json:"dropouts"`
+	Risers   []movement `json:"risers"`
+	Fallers  []movement `json:"fallers"`
+	Moves    []movement `json:"moves"`
+}
+
+func diffCmd(args []string) {
+	fl := flag.NewFlagSet("diff", flag.ExitOnError)
+	sd := fl.String("s", "", "snapshot directory, the two latest snapshots are compared when no snapshots are given")
+	format := fl.String("f", "md", "output format: json or md")
+	top := fl.Int("n", 100, "size of the top of the ranking in which entrants, dropouts, risers and fallers are reported")
+	of := fl.String("o", "", "output file, stdout by default")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: modranker diff [flags] [old new]")
+		fmt.Fprintln(fl.Output(), "old and new are dependency graph files or snapshot ids from the snapshot directory")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+
+	refs := fl.Args()
+	if len(refs) == 0 && *sd != "" {
+		idx, err := readIndex(*sd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(idx) < 2 {
+			log.Fatalf("at least two snapshots are needed in %s", *sd)
+		}
+		refs = []string{idx[len(idx)-2].ID, idx[len(idx)-1].ID}
+	}
+	if len(refs) != 2 {
+		fl.Usage()
+		os.Exit(2)
+	}
+	old, err := loadSnapshot(*sd, refs[0])
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", refs[0], err)
+	}
+	cur, err := loadSnapshot(*sd, refs[1])
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", refs[1], err)
+	}
+	d := diffGraphs(old, cur, *top)
+	d.Old, d.New = refs[0], refs[1]
+
+	out := os.Stdout
+	if *of != "" {
+		out, err = os.Create(*of)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	switch *format {
+	case "json":
+		err = json.NewEncoder(out).Encode(d)
+	case "md":
+		err = d.writeMarkdown(out)
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// diffGraphs compares the rankings of two dependency graphs, modules are matched by their module path.
+// The seeds of personalized rankings are not ranked, they are left out.
+func diffGraphs(old, cur dgraph.Graph, top int) rankDiff {
+	d := rankDiff{Top: top}
+	oldRanks := map[string]int{}
+	for _, p := range old.Pkgs {
+		if !p.Seed {
+			oldRanks[p.ModuleName] = p.PRank
+		}
+	}
+	curRanks := map[string]int{}
+	for _, p := range cur.Pkgs {
+		if p.Seed {
+			continue
+		}
+		curRanks[p.ModuleName] = p.PRank
+		or, ok := oldRanks[p.ModuleName]
+		if !ok {
+			d.Added++
+			if p.PRank <= top {
+				d.Entrants = append(d.Entrants, movement{Module: p.ModuleName, NewRank: p.PRank})
+			}
+			continue
+		}
+		m := movement{Module: p.ModuleName, OldRank: or, NewRank: p.PRank, Change: or - p.PRank}
+		if p.PRank <= top && or > top {
+			d.Entrants = append(d.Entrants, m)
+		}
+		if p.PRank <= top && m.Change != 0 {
+			d.Moves = append(d.Moves, m)
+		}
+		// the moves at the tail of the ranking are mostly noise, only the top is reported
+		if p.PRank > top && or > top {
+			continue
+		}
+		if m.Change > 0 {
+			d.Risers = append(d.Risers, m)
+		} else if m.Change < 0 {
+			d.Fallers = append(d.Fallers, m)
+		}
+	}
+	for _, p := range old.Pkgs {
+		if p.Seed {
+			continue
+		}
+		nr, ok := curRanks[p.ModuleName]
+		if !ok {
+			d.Removed++
+		}
+		if p.PRank <= top && (!ok || nr > top) {
+			m := movement{Module: p.ModuleName, OldRank: p.PRank}
+			if ok {
+				m.NewRank, m.Change = nr, p.PRank-nr
+			}
+			d.Dropouts = append(d.Dropouts, m)
+		}
+	}
+	for _, l := range [][]movement{d.Entrants, d.Dropouts, d.Moves} {
+		l := l
+		sort.SliceStable(l, func(i, j int) bool {
+			if l[i].NewRank == 0 || l[j].NewRank == 0 {
+				return l[i].OldRank < l[j].OldRank
+			}
+			return l[i].NewRank < l[j].NewRank
+		})
+	}
+	sort.SliceStable(d.Risers, func(i, j int) bool { return d.Risers[i].Change > d.Risers[j].Change })
+	sort.SliceStable(d.Fallers, func(i, j int) bool { return d.Fallers[i].Change < d.Fallers[j].Change })
+	if len(d.Risers) > top {
+		d.Risers = d.Risers[:top]
+	}
+	if len(d.Fallers) > top {
+		d.Fallers = d.Fallers[:top]
+	}
+	return d
+}
+
+func (d rankDiff) writeMarkdown(w io.Writer) error {
+	var err error
+	p := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	p("# Ranking changes from %s to %s\n\n", d.Old, d.New)
+	p("%d modules added, %d modules removed.\n", d.Added, d.Removed)
+	table := func(title string, ms []movement) {
+		p("\n## %s\n\n", title)
+		if len(ms) == 0 {
+			p("None.\n")
+			return
+		}
+		p("| Module | Old rank | New rank | Change |\n|---|---|---|---|\n")
+		for _, m := range ms {
+			p("| %s | %s | %s | %+d |\n", m.Module, rankCell(m.OldRank), rankCell(m.NewRank), m.Change)
+		}
+	}
+	table(fmt.Sprintf("New entrants in the top %d", d.Top), d.Entrants)
+	table(fmt.Sprintf("Dropouts from the top %d", d.Top), d.Dropouts)
+	table("Biggest risers", d.Risers)
+	table("Biggest fallers", d.Fallers)
+	table(fmt.Sprintf("Movements in the top %d", d.Top), d.Moves)
+	return err
+}
+
+func rankCell(r int) string {
+	if r == 0 {
+		return "-"
+	}
+	return fmt.Sprint(r)
+}
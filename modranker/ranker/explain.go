@@ -0,0 +1,248 @@
+package ranker
+
+import (
+	"container/heap"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+type contribution struct {
+	Module string  `json:"module"`
+	Stars  int     `json:"stars"`
+	Rank   float64 `json:"rank"`
+	// WeightShare is the share of the dependent's outgoing edge weight going to the explained module.
+	WeightShare float64 `json:"weight_share"`
+	// Share is the part of the explained module's rank coming from the dependent.
+	Share float64 `json:"share"`
+}
+
+type inboundPath struct {
+	Modules  []string `json:"modules"`
+	Stars    int      `json:"stars"`
+	Strength float64  `json:"strength"`
+}
+
+type explanation struct {
+	Module        string         `json:"module"`
+	Rank          float64        `json:"rank"`
+	PRank         int            `json:"prank"`
+	Dependents    int            `json:"dependents"`
+	Contributors  []contribution `json:"contributors"`
+	TopKShare     float64        `json:"top_k_share"`
+	TeleportShare float64        `json:"teleport_share"`
+	Paths         []inboundPath  `json:"paths"`
+}
+
+// explain breaks down the rank of the module p into the contributions of its direct dependents:
+// a dependent passes α × its rank × the share of its outgoing edge weight to each of its dependencies,
+// the rest of the rank of p comes from teleporting. It also finds the strongest inbound paths to p
+// from the most starred modules depending on it directly or transitively, the strength of a path
+// is the product of the weight shares of its edges.
+func explain(dg dgraph.Graph, p dgraph.Pkg, α float64, k int) explanation {
+	pkgs := make(map[uint32]dgraph.Pkg, len(dg.Pkgs))
+	for _, q := range dg.Pkgs {
+		pkgs[q.ID] = q
+	}
+	out := outWeights(dg)
+	e := explanation{Module: p.ModuleName, Rank: p.Rank, PRank: p.PRank, Contributors: []contribution{}, Paths: []inboundPath{}}
+	var total float64
+	for _, d := range dg.Deps[p.ID] {
+		if d.Upstream {
+			continue
+		}
+		e.Dependents++
+		s := pkgs[d.PkgID]
+		c := contribution{Module: s.ModuleName, Stars: s.Stars, Rank: s.Rank, WeightShare: share(d.Weight, out[d.PkgID])}
+		if p.Rank > 0 {
+			c.Share = α * s.Rank * c.WeightShare / p.Rank
+		}
+		total += c.Share
+		e.Contributors = append(e.Contributors, c)
+	}
+	sort.Slice(e.Contributors, func(i, j int) bool { return e.Contributors[i].Share > e.Contributors[j].Share })
+	if len(e.Contributors) > k {
+		e.Contributors = e.Contributors[:k]
+	}
+	for _, c := range e.Contributors {
+		e.TopKShare += c.Share
+	}
+	e.TeleportShare = math.Max(0, 1-total)
+
+	strength, next := strongestPaths(dg, out, p.ID)
+	var sources []uint32
+	for s := range strength {
+		if s != p.ID {
+			sources = append(sources, s)
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		si, sj := pkgs[sources[i]].Stars, pkgs[sources[j]].Stars
+		if si != sj {
+			return si > sj
+		}
+		return strength[sources[i]] > strength[sources[j]]
+	})
+	if len(sources) > k {
+		sources = sources[:k]
+	}
+	for _, s := range sources {
+		ip := inboundPath{Stars: pkgs[s].Stars, Strength: strength[s]}
+		for c := s; ; c = next[c] {
+			ip.Modules = append(ip.Modules, pkgs[c].ModuleName)
+			if c == p.ID {
+				break
+			}
+		}
+		e.Paths = append(e.Paths, ip)
+	}
+	return e
+}
+
+// outWeights returns the sum of the weights of the dependencies of every module.
+func outWeights(dg dgraph.Graph) map[uint32]float64 {
+	out := make(map[uint32]float64, len(dg.Deps))
+	for s, deps := range dg.Deps {
+		for _, d := range deps {
+			if d.Upstream {
+				out[s] += d.Weight
+			}
+		}
+	}
+	return out
+}
+
+func share(w, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return w / total
+}
+
+// strongestPaths finds, for every module depending on t, the path to t with the highest product of
+// edge weight shares (Dijkstra on the -log of the shares). next is the next module on the path.
+func strongestPaths(dg dgraph.Graph, out map[uint32]float64, t uint32) (strength map[uint32]float64, next map[uint32]uint32) {
+	cost := map[uint32]float64{t: 0}
+	next = map[uint32]uint32{}
+	done := map[uint32]bool{}
+	q := &costQueue{{id: t}}
+	for q.Len() > 0 {
+		c := heap.Pop(q).(costItem)
+		if done[c.id] {
+			continue
+		}
+		done[c.id] = true
+		for _, d := range dg.Deps[c.id] {
+			if d.Upstream || done[d.PkgID] {
+				continue
+			}
+			ws := share(d.Weight, out[d.PkgID])
+			if ws <= 0 {
+				continue
+			}
+			nc := c.cost - math.Log(ws)
+			if oc, ok := cost[d.PkgID]; !ok || nc < oc {
+				cost[d.PkgID] = nc
+				next[d.PkgID] = c.id
+				heap.Push(q, costItem{id: d.PkgID, cost: nc})
+			}
+		}
+	}
+	strength = make(map[uint32]float64, len(cost))
+	for id, c := range cost {
+		strength[id] = math.Exp(-c)
+	}
+	return strength, next
+}
+
+type costItem struct {
+	id   uint32
+	cost float64
+}
+
+type costQueue []costItem
+
+func (q costQueue) Len() int            { return len(q) }
+func (q costQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q costQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *costQueue) Push(x interface{}) { *q = append(*q, x.(costItem)) }
+func (q *costQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+func (e explanation) writeText(w io.Writer) error {
+	var err error
+	p := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	p("%s: rank %g (#%d), %d direct dependents\n", e.Module, e.Rank, e.PRank, e.Dependents)
+	p("%.1f%% of the rank comes from the top %d dependents, %.1f%% from teleporting\n\n",
+		100*e.TopKShare, len(e.Contributors), 100*e.TeleportShare)
+	p("Top contributing dependents:\n")
+	for _, c := range e.Contributors {
+		p("  %6.2f%%  %s (rank %g, %d stars, %.1f%% of its edge weight)\n", 100*c.Share, c.Module, c.Rank, c.Stars, 100*c.WeightShare)
+	}
+	p("\nStrongest inbound paths from the most starred dependents:\n")
+	for _, ip := range e.Paths {
+		p("  %d stars, strength %.3g:", ip.Stars, ip.Strength)
+		for i, m := range ip.Modules {
+			if i > 0 {
+				p(" ->")
+			}
+			p(" %s", m)
+		}
+		p("\n")
+	}
+	return err
+}
+
+func explainCmd(args []string) {
+	fl := flag.NewFlagSet("explain", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	k := fl.Int("k", 10, "number of dependents and paths to report")
+	α := fl.Float64("alpha", 0.85, "damping factor the ranking was computed with")
+	format := fl.String("f", "text", "output format: text or json")
+	fl.Usage = func() {
+		fmt.Fprintln(fl.Output(), "Usage: modranker explain [flags] module")
+		fl.PrintDefaults()
+	}
+	fl.Parse(args)
+	if fl.NArg() != 1 {
+		fl.Usage()
+		os.Exit(2)
+	}
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	gi := newGraphIndex(dg)
+	p, ok := gi.pkg(fl.Arg(0))
+	if !ok {
+		log.Fatalf("module %s is not in %s", fl.Arg(0), *gf)
+	}
+	e := explain(dg, p, *α, *k)
+	switch *format {
+	case "json":
+		err = json.NewEncoder(os.Stdout).Encode(e)
+	case "text":
+		err = e.writeText(os.Stdout)
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,273 @@
+package ranker
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+type exportEdge struct {
+	source, target uint32
+	weight         float64
+}
+
+// subgraph returns the n best ranked modules (all of them when n <= 0) and the dependencies between them.
+func subgraph(dg dgraph.Graph, n int) ([]dgraph.Pkg, []exportEdge) {
+	ps := dg.Pkgs
+	if n > 0 && n < len(ps) {
+		ps = ps[:n]
+	}
+	in := make(map[uint32]bool, len(ps))
+	for _, p := range ps {
+		in[p.ID] = true
+	}
+	var es []exportEdge
+	for _, p := range ps {
+		for _, d := range dg.Deps[p.ID] {
+			if d.Upstream && in[d.PkgID] {
+				es = append(es, exportEdge{source: p.ID, target: d.PkgID, weight: d.Weight})
+			}
+		}
+	}
+	return ps, es
+}
+
+func exportCmd(args []string) {
+	fl := flag.NewFlagSet("export", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	format := fl.String("f", "graphml", "output format: graphml, gexf or dot")
+	n := fl.Int("n", 0, "export only the top n modules and the dependencies between them, 0 exports all")
+	of := fl.String("o", "", "output file, stdout by default")
+	fl.Parse(args)
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	var write func(io.Writer, []dgraph.Pkg, []exportEdge) error
+	switch *format {
+	case "graphml":
+		write = writeGraphML
+	case "gexf":
+		write = writeGEXF
+	case "dot":
+		write = writeDOT
+	default:
+		log.Fatalf("unknown format %s", *format)
+	}
+	out := os.Stdout
+	if *of != "" {
+		out, err = os.Create(*of)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	bw := bufio.NewWriter(out)
+	ps, es := subgraph(dg, *n)
+	if err = write(bw, ps, es); err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	NS      string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func nodeAttrs(p dgraph.Pkg) []string {
+	return []string{
+		p.ModuleName,
+		strconv.FormatFloat(p.Rank, 'g', -1, 64),
+		strconv.Itoa(p.PRank),
+		strconv.Itoa(p.Stars),
+		strconv.Itoa(p.Imports),
+		strings.Join(p.Topics, ","),
+	}
+}
+
+var nodeAttrNames = []string{"label", "rank", "prank", "stars", "imports", "topics"}
+var nodeAttrTypes = []string{"string", "double", "int", "int", "int", "string"}
+
+func writeGraphML(w io.Writer, ps []dgraph.Pkg, es []exportEdge) error {
+	g := graphML{NS: "http://graphml.graphdrawing.org/xmlns"}
+	for i, n := range nodeAttrNames {
+		g.Keys = append(g.Keys, graphMLKey{ID: n, For: "node", Name: n, Type: nodeAttrTypes[i]})
+	}
+	g.Keys = append(g.Keys, graphMLKey{ID: "weight", For: "edge", Name: "weight", Type: "double"})
+	g.Graph.EdgeDefault = "directed"
+	for _, p := range ps {
+		n := graphMLNode{ID: fmt.Sprint(p.ID)}
+		for i, v := range nodeAttrs(p) {
+			n.Data = append(n.Data, graphMLData{Key: nodeAttrNames[i], Value: v})
+		}
+		g.Graph.Nodes = append(g.Graph.Nodes, n)
+	}
+	for _, e := range es {
+		g.Graph.Edges = append(g.Graph.Edges, graphMLEdge{
+			Source: fmt.Sprint(e.source),
+			Target: fmt.Sprint(e.target),
+			Data:   []graphMLData{{Key: "weight", Value: strconv.FormatFloat(e.weight, 'g', -1, 64)}},
+		})
+	}
+	return writeXML(w, g)
+}
+
+type gexfAttr struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     int     `xml:"id,attr"`
+	Source string  `xml:"source,attr"`
+	Target string  `xml:"target,attr"`
+	Weight float64 `xml:"weight,attr"`
+}
+
+type gexf struct {
+	XMLName xml.Name `xml:"gexf"`
+	NS      string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string `xml:"defaultedgetype,attr"`
+		Attributes      struct {
+			Class string     `xml:"class,attr"`
+			Attrs []gexfAttr `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfNode `xml:"nodes>node"`
+		Edges []gexfEdge `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+func writeGEXF(w io.Writer, ps []dgraph.Pkg, es []exportEdge) error {
+	g := gexf{NS: "http://www.gexf.net/1.2draft", Version: "1.2"}
+	g.Graph.DefaultEdgeType = "directed"
+	g.Graph.Attributes.Class = "node"
+	// the label is a built-in node attribute in GEXF
+	for i, n := range nodeAttrNames[1:] {
+		g.Graph.Attributes.Attrs = append(g.Graph.Attributes.Attrs, gexfAttr{ID: n, Title: n, Type: gexfType(nodeAttrTypes[i+1])})
+	}
+	for _, p := range ps {
+		vs := nodeAttrs(p)
+		n := gexfNode{ID: fmt.Sprint(p.ID), Label: vs[0]}
+		for i, v := range vs[1:] {
+			n.Values = append(n.Values, gexfValue{For: nodeAttrNames[i+1], Value: v})
+		}
+		g.Graph.Nodes = append(g.Graph.Nodes, n)
+	}
+	for i, e := range es {
+		g.Graph.Edges = append(g.Graph.Edges, gexfEdge{ID: i, Source: fmt.Sprint(e.source), Target: fmt.Sprint(e.target), Weight: e.weight})
+	}
+	return writeXML(w, g)
+}
+
+func gexfType(t string) string {
+	if t == "int" {
+		return "integer"
+	}
+	return t
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// dotWeightScale is the weight of the heaviest edge in the DOT export,
+// Graphviz only takes integer weights so the others are scaled to it.
+const dotWeightScale = 100
+
+func writeDOT(w io.Writer, ps []dgraph.Pkg, es []exportEdge) error {
+	var err error
+	p := func(format string, a ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, a...)
+		}
+	}
+	var max float64
+	for _, e := range es {
+		max = math.Max(max, e.weight)
+	}
+	p("digraph grank {\n")
+	for _, pk := range ps {
+		p("  %d [label=%s rank=\"%v\" prank=%d stars=%d imports=%d topics=%s];\n",
+			pk.ID, dotQuote(pk.ModuleName), pk.Rank, pk.PRank, pk.Stars, pk.Imports, dotQuote(strings.Join(pk.Topics, ",")))
+	}
+	for _, e := range es {
+		var sw int
+		if max > 0 {
+			sw = int(math.Round(dotWeightScale * e.weight / max))
+		}
+		// the layout weight is the scaled one, w keeps the exact weight
+		p("  %d -> %d [weight=%d w=\"%v\"];\n", e.source, e.target, sw, e.weight)
+	}
+	p("}\n")
+	return err
+}
+
+// dotQuote returns s as a DOT double quoted string, only the quotes and the backslashes are escaped.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,29 @@
+package ranker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestWriteDOT(t *testing.T) {
+	ps := []dgraph.Pkg{{ID: 1, ModuleName: `example.com/a"b\c`, Topics: []string{"cli", "é"}}}
+	es := []exportEdge{{1, 2, 0.5}, {1, 3, 2}, {2, 3, 0.001}}
+	var b bytes.Buffer
+	if err := writeDOT(&b, ps, es); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`label="example.com/a\"b\\c"`,
+		`topics="cli,é"`,
+		`1 -> 2 [weight=25 w="0.5"]`,
+		`1 -> 3 [weight=100 w="2"]`,
+		`2 -> 3 [weight=0 w="0.001"]`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %s in\n%s", want, b.String())
+		}
+	}
+}
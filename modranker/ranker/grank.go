@@ -0,0 +1,67 @@
+package ranker
+
+import (
+	"math"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+var tiers = []struct {
+	share float64
+	label string
+}{
+	{0.001, "top 0.1%"},
+	{0.01, "top 1%"},
+	{0.1, "top 10%"},
+}
+
+// scoreRanks sets the GRank and the tier of the modules ordered by rank, α is the damping factor of the ranking.
+//
+// The GRank is the PageRank mapped to a 0-100 scale logarithmically, relative to the uniform rank 1/N
+// of the N modules instead of the extremes of the run, so that a module's score only moves when its
+// share of the rank does: 0 is the rank got by teleports only, (1-α)/N, and 100 is all the rank.
+// PageRank values span several orders of magnitude and follow a power law, so the logarithmic scale
+// keeps the scores of the bulk of the modules apart, while rounding to integers makes the score
+// insensitive to the tiny float differences between runs.
+// The tier is given by the position of the module in the ranking, modules sharing a PRank share the tier.
+func scoreRanks(ps []dgraph.Pkg, α float64) {
+	// log(rank·N) is 0 for the uniform rank, lo and hi are the bounds of the scale
+	lo, hi := math.Log(math.Max(1-α, 1e-9)), math.Log(float64(len(ps)))
+	// the seeds are not ranked, the tiers are shares of the ranked modules
+	var n int
+	for _, p := range ps {
+		if !p.Seed {
+			n++
+		}
+	}
+	pos, ranked, prev := 0, 0, -1
+	for i := range ps {
+		if !ps[i].Seed {
+			if ps[i].PRank != prev {
+				pos = ranked
+			}
+			prev = ps[i].PRank
+			ranked++
+		}
+		// personalized rankings may contain zero ranks and ranks below the teleport share, they get a 0 GRank
+		switch {
+		case ps[i].Rank <= 0:
+			ps[i].GRank = 0
+		case hi > lo:
+			g := 100 * (math.Log(ps[i].Rank*float64(len(ps))) - lo) / (hi - lo)
+			ps[i].GRank = int(math.Round(math.Min(math.Max(g, 0), 100)))
+		default:
+			ps[i].GRank = 100
+		}
+		ps[i].Tier = ""
+		if ps[i].Seed {
+			continue
+		}
+		for _, t := range tiers {
+			if float64(pos+1) <= t.share*float64(n) {
+				ps[i].Tier = t.label
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestScoreRanksScale(t *testing.T) {
+	const α = 0.85
+	tests := []struct {
+		name  string
+		ranks []float64
+		want  []int
+	}{
+		{"uniform", []float64{0.25, 0.25, 0.25, 0.25}, []int{58, 58, 58, 58}},
+		{"below teleports", []float64{0.9625, 0.0375}, []int{99, 0}},
+		{"all", []float64{1, 0}, []int{100, 0}},
+		{"teleports only", []float64{0.9, 0.05, 0.05}, []int{96, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ps []dgraph.Pkg
+			for i, r := range tt.ranks {
+				ps = append(ps, dgraph.Pkg{Rank: r, PRank: i + 1})
+			}
+			scoreRanks(ps, α)
+			for i, p := range ps {
+				if p.GRank != tt.want[i] {
+					t.Errorf("GRank of rank %g is %d, want %d", p.Rank, p.GRank, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// The score of a module only depends on its share of the rank, not on the other modules.
+func TestScoreRanksStable(t *testing.T) {
+	a := []dgraph.Pkg{{Rank: 0.5, PRank: 1}, {Rank: 0.3, PRank: 2}, {Rank: 0.2, PRank: 3}}
+	b := []dgraph.Pkg{{Rank: 0.5, PRank: 1}, {Rank: 0.45, PRank: 2}, {Rank: 0.05, PRank: 3}}
+	scoreRanks(a, 0.85)
+	scoreRanks(b, 0.85)
+	if a[0].GRank != b[0].GRank {
+		t.Errorf("GRank of the same rank changed from %d to %d", a[0].GRank, b[0].GRank)
+	}
+}
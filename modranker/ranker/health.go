@@ -0,0 +1,171 @@
+package ranker
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// defaultStaleAfter is the time since the last push after which a repository is considered
+// unmaintained by default.
+const defaultStaleAfter = 2 * 365 * 24 * time.Hour
+
+// healthColumns are the columns of the health report.
+var healthColumns = []string{"prank", "module_name", "unmaintained", "direct_deps", "transitive_deps", "depth", "unmaintained_deps", "dependent_bus_factor"}
+
+// unmaintained tells whether the repository is archived or was not pushed to for rr.staleAfter.
+func (rr *rankRun) unmaintained(r github.Repository) bool {
+	return r.GetArchived() || r.PushedAt != nil && rr.now.Sub(r.PushedAt.Time) > rr.staleAfter
+}
+
+// owner returns the owner of the module's repository, e.g. github.com/spf13, or the first two
+// elements of the module path when the repository is not known.
+func owner(p dgraph.Pkg) string {
+	n := p.RepoName
+	if n == "" {
+		n = p.ModuleName
+	}
+	return ownerOf(n)
+}
+
+// ownerOf returns the first two elements of the repository or module path.
+func ownerOf(path string) string {
+	e := strings.SplitN(path, "/", 3)
+	if len(e) < 2 {
+		return path
+	}
+	return e[0] + "/" + e[1]
+}
+
+// traversalHealthColumns are the health metrics computed from the transitive dependencies.
+var traversalHealthColumns = []string{"transitive_deps", "depth", "unmaintained_deps"}
+
+// annotateHealth sets the dependency graph health metrics of the modules which don't need a
+// traversal of the graph: whether they are unmaintained, the number of their direct dependencies
+// and the bus factor of their dependents: the least number of owners whose modules make up at
+// least half of the dependents.
+func (rr *rankRun) annotateHealth(dg dgraph.Graph, repos map[string]github.Repository) {
+	for i, p := range dg.Pkgs {
+		r, ok := repos[p.RepoName]
+		dg.Pkgs[i].Unmaintained = ok && rr.unmaintained(r)
+	}
+	owners := make(map[uint32]string, len(dg.Pkgs))
+	for _, p := range dg.Pkgs {
+		owners[p.ID] = owner(p)
+	}
+	for i, p := range dg.Pkgs {
+		h := &dg.Pkgs[i]
+		h.DirectDeps = 0
+		perOwner := map[string]int{}
+		var dependents int
+		for _, d := range dg.Deps[p.ID] {
+			if d.Upstream {
+				h.DirectDeps++
+			} else {
+				perOwner[owners[d.PkgID]]++
+				dependents++
+			}
+		}
+		counts := make([]int, 0, len(perOwner))
+		for _, c := range perOwner {
+			counts = append(counts, c)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+		h.DependentBusFactor = 0
+		for covered := 0; 2*covered < dependents; h.DependentBusFactor++ {
+			covered += counts[h.DependentBusFactor]
+		}
+	}
+}
+
+// healthVisitor sets the health metrics of the modules computed from their transitive
+// dependencies: their number, the depth of the dependency tree (the longest of the shortest
+// paths to the dependencies) and the number of unmaintained modules among them.
+// annotateHealth has to run first.
+func healthVisitor(dg dgraph.Graph) depVisitor {
+	stale := map[uint32]bool{}
+	for i, p := range dg.Pkgs {
+		dg.Pkgs[i].TransitiveDeps, dg.Pkgs[i].Depth, dg.Pkgs[i].UnmaintainedDeps = 0, 0, 0
+		if p.Unmaintained {
+			stale[p.ID] = true
+		}
+	}
+	return func(i int, dep uint32, depth int) {
+		h := &dg.Pkgs[i]
+		h.TransitiveDeps++
+		h.Depth = depth
+		if stale[dep] {
+			h.UnmaintainedDeps++
+		}
+	}
+}
+
+// depVisitor is called with the transitive dependencies of the module dg.Pkgs[i] in breadth
+// first order, depth is the length of the shortest path to the dependency.
+type depVisitor func(i int, dep uint32, depth int)
+
+// walkDeps traverses the transitive dependencies of every module once, calling all the visitors,
+// a traversal per module is costly on large graphs so the metrics needing it share it.
+func walkDeps(dg dgraph.Graph, visitors ...depVisitor) {
+	if len(visitors) == 0 {
+		return
+	}
+	for i, p := range dg.Pkgs {
+		seen := map[uint32]bool{p.ID: true}
+		level := []uint32{p.ID}
+		for depth := 1; len(level) > 0; depth++ {
+			var next []uint32
+			for _, c := range level {
+				for _, d := range dg.Deps[c] {
+					if !d.Upstream || seen[d.PkgID] {
+						continue
+					}
+					seen[d.PkgID] = true
+					next = append(next, d.PkgID)
+					for _, v := range visitors {
+						v(i, d.PkgID, depth)
+					}
+				}
+			}
+			level = next
+		}
+	}
+}
+
+// writeHealthReport writes the health metrics of the modules in rank order to fn.
+func writeHealthReport(fn, format string, dg dgraph.Graph) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = writeHealth(f, format, dg)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeHealth(w io.Writer, format string, dg dgraph.Graph) error {
+	idx, err := pkgColumns(healthColumns)
+	if err != nil {
+		return err
+	}
+	tw, err := newTableWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err = tw.Write(healthColumns); err != nil {
+		return err
+	}
+	for i, p := range dg.Pkgs {
+		if err = tw.Write(pkgRow(i, p, idx)); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
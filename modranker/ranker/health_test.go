@@ -0,0 +1,39 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestWalkDeps(t *testing.T) {
+	// 1 -> 2 -> 3 -> 4 and 1 -> 4, 4 is unmaintained and copyleft, 3 is unlicensed
+	dg := testGraph(deps(1, 2, 2, 3, 3, 4, 1, 4))
+	dg.Pkgs = []dgraph.Pkg{
+		{ID: 1, LicenseKind: permissive},
+		{ID: 2, LicenseKind: permissive},
+		{ID: 3, LicenseKind: noLicense},
+		{ID: 4, LicenseKind: copyleft, Unmaintained: true},
+	}
+	offending := map[uint32][]uint32{}
+	walkDeps(dg, healthVisitor(dg), licenseVisitor(dg, offending))
+	tests := []struct {
+		transitive, depth, unmaintained, copyleft, unlicensed int
+	}{
+		{3, 2, 1, 1, 1},
+		{2, 2, 1, 1, 1},
+		{1, 1, 1, 1, 0},
+		{0, 0, 0, 0, 0},
+	}
+	for i, tt := range tests {
+		p := dg.Pkgs[i]
+		got := [5]int{p.TransitiveDeps, p.Depth, p.UnmaintainedDeps, p.CopyleftDeps, p.UnlicensedDeps}
+		want := [5]int{tt.transitive, tt.depth, tt.unmaintained, tt.copyleft, tt.unlicensed}
+		if got != want {
+			t.Errorf("module %d: got transitive, depth, unmaintained, copyleft, unlicensed %v, want %v", p.ID, got, want)
+		}
+		if len(offending[p.ID]) != tt.copyleft+tt.unlicensed {
+			t.Errorf("module %d: got offending %v", p.ID, offending[p.ID])
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package ranker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dependency kinds, based on the files importing the packages of the dependency
+const (
+	runtimeDep = "runtime"
+	testDep    = "test"
+	toolDep    = "tool"
+)
+
+const (
+	runtimeUse = 1 << iota
+	testUse
+	toolUse
+)
+
+// kindsCache is the file in the module directory caching the kinds of its dependencies.
+const kindsCache = ".grank-kinds.json"
+
+// kindsVersion is part of the cache key, it has to be changed with the classification.
+const kindsVersion = 1
+
+type cachedKinds struct {
+	Key   string            `json:"key"`
+	Kinds map[string]string `json:"kinds"`
+}
+
+// cachedClassifyDeps returns the kinds of the dependencies of m like classifyDeps, cached in the
+// module directory. The downloaded files only change when the repository is fetched again, which
+// rewrites the go.mod file, so the cache is keyed by its content and modification time.
+func (rr *rankRun) cachedClassifyDeps(m mod) map[string]string {
+	key, err := kindsKey(m)
+	if err != nil {
+		return rr.classifyDeps(m)
+	}
+	fn := filepath.Join(m.Dir, kindsCache)
+	var c cachedKinds
+	if b, err := ioutil.ReadFile(fn); err == nil && json.Unmarshal(b, &c) == nil && c.Key == key {
+		return c.Kinds
+	}
+	c = cachedKinds{Key: key, Kinds: rr.classifyDeps(m)}
+	b, err := json.Marshal(c)
+	if err == nil {
+		err = ioutil.WriteFile(fn, b, 0644)
+	}
+	if err != nil && rr.verbose {
+		log.Printf("failed to cache the dependency kinds of %s: %v", m.Path, err)
+	}
+	return c.Kinds
+}
+
+func kindsKey(m mod) (string, error) {
+	fn := filepath.Join(m.Dir, "go.mod")
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return "", err
+	}
+	c, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d %d\n", kindsVersion, fi.ModTime().UnixNano())
+	h.Write(c)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// classifyDeps returns the kind of the direct dependencies of m which are imported by the go files of the module.
+// A dependency imported by any regular file is a runtime one, otherwise it's a test dependency
+// if it is imported from _test.go files and a tool dependency if it's only imported from files
+// constrained by the tools build tag (e.g. tools.go with //go:build tools).
+func (rr *rankRun) classifyDeps(m mod) map[string]string {
+	uses := map[string]int{}
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(m.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		n := d.Name()
+		if d.IsDir() {
+			if path == m.Dir {
+				return nil
+			}
+			if n == "testdata" || n == "vendor" || strings.HasPrefix(n, ".") || strings.HasPrefix(n, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				// nested module
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(n) != ".go" {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			if rr.verbose {
+				log.Printf("failed to parse %s: %v", path, err)
+			}
+			return nil
+		}
+		use := runtimeUse
+		if strings.HasSuffix(n, "_test.go") {
+			use = testUse
+		}
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			for _, c := range cg.List {
+				if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+					continue
+				}
+				if expr, err := constraint.Parse(c.Text); err == nil && requiresTag(expr, "tools") {
+					use = toolUse
+				}
+			}
+		}
+		for _, is := range f.Imports {
+			ip, err := strconv.Unquote(is.Path.Value)
+			if err != nil {
+				continue
+			}
+			if dp := requiredModule(m, ip); dp != "" {
+				uses[dp] |= use
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to scan the files of %s: %v", m.Path, err)
+	}
+	kinds := map[string]string{}
+	for dp, u := range uses {
+		switch {
+		case u&runtimeUse != 0:
+			kinds[dp] = runtimeDep
+		case u&testUse != 0:
+			kinds[dp] = testDep
+		default:
+			kinds[dp] = toolDep
+		}
+	}
+	return kinds
+}
+
+// requiresTag tells whether the build constraint is only satisfied when the tag is set.
+func requiresTag(expr constraint.Expr, tag string) bool {
+	return expr.Eval(func(string) bool { return true }) &&
+		!expr.Eval(func(t string) bool { return t != tag })
+}
+
+// requiredModule returns the direct dependency of m providing the imported package.
+func requiredModule(m mod, ip string) string {
+	var best string
+	for _, dp := range m.DirectDeps {
+		if (ip == dp || strings.HasPrefix(ip, dp+"/")) && len(dp) > len(best) {
+			best = dp
+		}
+	}
+	return best
+}
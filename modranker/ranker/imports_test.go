@@ -0,0 +1,50 @@
+package ranker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCachedClassifyDeps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, body string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/m\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0\n)\n")
+	write("m.go", "package m\n\nimport _ \"example.com/a/pkg\"\n")
+	write("m_test.go", "package m\n\nimport _ \"example.com/b\"\n")
+	m := mod{Path: "example.com/m", Dir: dir, DirectDeps: []string{"example.com/a", "example.com/b"}}
+	want := map[string]string{"example.com/a": runtimeDep, "example.com/b": testDep}
+	rr := newRankRun()
+	if got := rr.cachedClassifyDeps(m); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// the cached kinds are used while the go.mod file doesn't change
+	if err = os.Remove(filepath.Join(dir, "m_test.go")); err != nil {
+		t.Fatal(err)
+	}
+	if got := rr.cachedClassifyDeps(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v from the cache, want %v", got, want)
+	}
+
+	// a fetch rewrites the go.mod file
+	later := time.Now().Add(time.Minute)
+	if err = os.Chtimes(filepath.Join(dir, "go.mod"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{"example.com/a": runtimeDep}
+	if got := rr.cachedClassifyDeps(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after a fetch, want %v", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package ranker
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/license"
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+const (
+	permissive   = "permissive"
+	weakCopyleft = "weak-copyleft"
+	copyleft     = "copyleft"
+	otherLicense = "other"
+	noLicense    = "none"
+)
+
+// licenseTexts identifies the licenses by phrases of their texts, the more specific ones come first.
+// The GNU licenses refer to each other, they are told apart by their title and version lines.
+var licenseTexts = []struct {
+	spdx    string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE Version 3, 19 November 2007"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE Version 3, 29 June 2007"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE Version 2.1, February 1999"}},
+	{"LGPL-2.0", []string{"GNU LIBRARY GENERAL PUBLIC LICENSE Version 2, June 1991"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE Version 3, 29 June 2007"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE Version 2, June 1991"}},
+	{"MPL-2.0", []string{"Mozilla Public License Version 2.0"}},
+	{"EPL-2.0", []string{"Eclipse Public License - v 2.0"}},
+	{"EPL-1.0", []string{"Eclipse Public License"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software for any purpose", "provided that the above copyright notice"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"CC0 1.0 Universal"}},
+	{"0BSD", []string{"Permission to use, copy, modify, and/or distribute this software for any purpose"}},
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// classifyLicense returns the SPDX id of the license text, empty when it is not recognized.
+func classifyLicense(text string) string {
+	text = strings.ToUpper(spaces.ReplaceAllString(text, " "))
+	for _, l := range licenseTexts {
+		found := true
+		for _, p := range l.phrases {
+			if !strings.Contains(text, strings.ToUpper(p)) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return l.spdx
+		}
+	}
+	return ""
+}
+
+// licenseKind groups the SPDX ids by the obligations they put on the dependents.
+func licenseKind(spdx string) string {
+	switch {
+	case spdx == "":
+		return noLicense
+	case strings.HasPrefix(spdx, "AGPL"), strings.HasPrefix(spdx, "GPL"), strings.HasPrefix(spdx, "OSL"),
+		strings.HasPrefix(spdx, "EUPL"), strings.HasPrefix(spdx, "CC-BY-SA"):
+		return copyleft
+	case strings.HasPrefix(spdx, "LGPL"), strings.HasPrefix(spdx, "MPL"), strings.HasPrefix(spdx, "EPL"):
+		return weakCopyleft
+	case strings.HasPrefix(spdx, "MIT"), strings.HasPrefix(spdx, "BSD"), strings.HasPrefix(spdx, "Apache"),
+		spdx == "ISC", spdx == "0BSD", spdx == "Unlicense", spdx == "CC0-1.0", spdx == "Zlib", spdx == "BSL-1.0", spdx == "WTFPL":
+		return permissive
+	}
+	return otherLicense
+}
+
+// licenseFiles returns the license files of the directory.
+func licenseFiles(dir string) []string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var l []string
+	for _, f := range fis {
+		if !f.IsDir() && license.IsFile(f.Name()) {
+			l = append(l, filepath.Join(dir, f.Name()))
+		}
+	}
+	return l
+}
+
+// moduleLicense returns the SPDX id of the license of the module. The license files retained
+// by fetcharchive in the module's directory or in its parents up to the repository root take
+// precedence over the license GitHub detected for the whole repository. A license file which
+// is not recognized gives NOASSERTION, as GitHub does.
+func moduleLicense(downloadDir string, m mod, repo github.Repository) string {
+	pp := strings.Split(m.File, "/")
+	root := filepath.Join(append([]string{downloadDir}, pp[:3]...)...)
+	unknown := false
+	for dir := m.Dir; ; dir = filepath.Dir(dir) {
+		for _, fn := range licenseFiles(dir) {
+			c, err := ioutil.ReadFile(fn)
+			if err != nil {
+				continue
+			}
+			if l := classifyLicense(string(c)); l != "" {
+				return l
+			}
+			unknown = true
+		}
+		if unknown || len(dir) <= len(root) || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	if l := repo.GetLicense().GetSPDXID(); l != "" {
+		return l
+	}
+	if unknown {
+		return "NOASSERTION"
+	}
+	return ""
+}
+
+// licenseDepsColumns are the ranking table columns computed from the transitive dependencies.
+var licenseDepsColumns = []string{"copyleft_deps", "unlicensed_deps"}
+
+// annotateLicenses sets the license of the modules downloaded to downloadDir.
+func annotateLicenses(dg dgraph.Graph, downloadDir string, modules []mod, repos map[string]github.Repository) {
+	licenses := map[string]string{}
+	for _, m := range modules {
+		licenses[m.Path] = moduleLicense(downloadDir, m, repos[m.Repo])
+	}
+	for i, p := range dg.Pkgs {
+		l, ok := licenses[p.ModuleName]
+		if !ok {
+			continue
+		}
+		dg.Pkgs[i].License = l
+		dg.Pkgs[i].LicenseKind = licenseKind(l)
+	}
+}
+
+// licenseVisitor counts the copyleft and unlicensed modules in the transitive dependencies of
+// the modules and collects them into offending by module. The licenses of the dependencies
+// which were not downloaded are unknown, they are not counted.
+func licenseVisitor(dg dgraph.Graph, offending map[uint32][]uint32) depVisitor {
+	kinds := make(map[uint32]string, len(dg.Pkgs))
+	for i, p := range dg.Pkgs {
+		kinds[p.ID] = p.LicenseKind
+		dg.Pkgs[i].CopyleftDeps, dg.Pkgs[i].UnlicensedDeps = 0, 0
+	}
+	return func(i int, dep uint32, depth int) {
+		p := &dg.Pkgs[i]
+		switch kinds[dep] {
+		case copyleft, weakCopyleft:
+			p.CopyleftDeps++
+		case noLicense:
+			p.UnlicensedDeps++
+		default:
+			return
+		}
+		offending[p.ID] = append(offending[p.ID], dep)
+	}
+}
+
+// licenseReportColumns are the columns of the license report, a row per offending dependency.
+var licenseReportColumns = []string{"prank", "module_name", "license", "copyleft_deps", "unlicensed_deps", "dependency", "dependency_license", "dependency_license_kind"}
+
+// writeLicenseReport lists the modules with copyleft or unlicensed modules in their
+// transitive dependencies, in rank order, with the offending dependencies collected by licenseVisitor.
+func writeLicenseReport(fn, format string, dg dgraph.Graph, offending map[uint32][]uint32) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = writeLicenses(f, format, dg, offending)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeLicenses(w io.Writer, format string, dg dgraph.Graph, offending map[uint32][]uint32) error {
+	tw, err := newTableWriter(w, format)
+	if err != nil {
+		return err
+	}
+	if err = tw.Write(licenseReportColumns); err != nil {
+		return err
+	}
+	pkgs := make(map[uint32]dgraph.Pkg, len(dg.Pkgs))
+	for _, p := range dg.Pkgs {
+		pkgs[p.ID] = p
+	}
+	for _, m := range dg.Pkgs {
+		if m.CopyleftDeps == 0 && m.UnlicensedDeps == 0 {
+			continue
+		}
+		deps := make([]dgraph.Pkg, 0, len(offending[m.ID]))
+		for _, id := range offending[m.ID] {
+			deps = append(deps, pkgs[id])
+		}
+		sort.Slice(deps, func(i, j int) bool { return deps[i].ModuleName < deps[j].ModuleName })
+		for _, d := range deps {
+			row := []string{strconv.Itoa(m.PRank), m.ModuleName, m.License, strconv.Itoa(m.CopyleftDeps), strconv.Itoa(m.UnlicensedDeps),
+				d.ModuleName, d.License, d.LicenseKind}
+			if err = tw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Flush()
+}
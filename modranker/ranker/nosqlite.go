@@ -0,0 +1,18 @@
+//go:build !sqlite
+// +build !sqlite
+
+package ranker
+
+import (
+	"errors"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// sqliteSupported tells whether the SQLite driver is built in, it needs cgo and the sqlite build tag.
+const sqliteSupported = false
+
+func exportSQLite(fn string, repos []github.Repository, modules []mod, nodes map[string]uint32, dg dgraph.Graph) error {
+	return errors.New("modranker is built without SQLite support, rebuild it with -tags sqlite")
+}
@@ -0,0 +1,189 @@
+package ranker
+
+import (
+	"encoding/json"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+	"golang.org/x/mod/semver"
+)
+
+// osvEntry is the part of an OSV vulnerability record (https://ossf.github.io/osv-schema/) used for the overlay.
+type osvEntry struct {
+	ID        string        `json:"id"`
+	Aliases   []string      `json:"aliases"`
+	Withdrawn string        `json:"withdrawn"`
+	Affected  []osvAffected `json:"affected"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string              `json:"type"`
+		Events []map[string]string `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+type moduleVuln struct {
+	entry    *osvEntry
+	affected osvAffected
+}
+
+// loadOSV reads the OSV records from the json files under dir and returns the vulnerabilities of the Go modules.
+func loadOSV(dir string) (map[string][]moduleVuln, error) {
+	vulns := map[string][]moduleVuln{}
+	return vulns, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		c, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		e := &osvEntry{}
+		if err = json.Unmarshal(c, e); err != nil || e.ID == "" {
+			log.Printf("skipping %s, not an OSV record: %v", path, err)
+			return nil
+		}
+		if e.Withdrawn != "" {
+			return nil
+		}
+		for _, a := range e.Affected {
+			if a.Package.Ecosystem == "Go" {
+				vulns[a.Package.Name] = append(vulns[a.Package.Name], moduleVuln{entry: e, affected: a})
+			}
+		}
+		return nil
+	})
+}
+
+func canonicalVersion(v string) string {
+	if v != "" && !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
+
+// affects tells whether the version is in the affected versions or ranges of the record.
+func (a osvAffected) affects(v string) bool {
+	v = canonicalVersion(v)
+	if !semver.IsValid(v) {
+		return false
+	}
+	for _, av := range a.Versions {
+		if semver.Compare(canonicalVersion(av), v) == 0 {
+			return true
+		}
+	}
+	for _, r := range a.Ranges {
+		if r.Type != "SEMVER" {
+			continue
+		}
+		type event struct{ kind, version string }
+		var events []event
+		for _, e := range r.Events {
+			for k, ev := range e {
+				if ev == "0" {
+					ev = "v0.0.0-0"
+				}
+				events = append(events, event{k, canonicalVersion(ev)})
+			}
+		}
+		sort.SliceStable(events, func(i, j int) bool { return semver.Compare(events[i].version, events[j].version) < 0 })
+		affected := false
+		for _, e := range events {
+			c := semver.Compare(v, e.version)
+			switch e.kind {
+			case "introduced":
+				if c >= 0 {
+					affected = true
+				}
+			case "fixed":
+				if c >= 0 {
+					affected = false
+				}
+			case "last_affected":
+				if c > 0 {
+					affected = false
+				}
+			}
+		}
+		if affected {
+			return true
+		}
+	}
+	return false
+}
+
+// annotateVulns adds the vulnerabilities affecting the versions required by the dependents of every module
+// and computes its vulnerable reach: the rank share of the modules requiring an affected version
+// of it directly, or transitively through such a module.
+func annotateVulns(dg dgraph.Graph, vulns map[string][]moduleVuln) {
+	idx := make(map[uint32]int, len(dg.Pkgs))
+	var total float64
+	for i, p := range dg.Pkgs {
+		idx[p.ID] = i
+		total += p.Rank
+	}
+	for i, p := range dg.Pkgs {
+		mvs := vulns[p.ModuleName]
+		if len(mvs) == 0 {
+			continue
+		}
+		exposed := map[uint32]bool{}
+		for _, mv := range mvs {
+			v := dgraph.Vuln{ID: mv.entry.ID, Aliases: mv.entry.Aliases}
+			for _, d := range dg.Deps[p.ID] {
+				if !d.Upstream && mv.affected.affects(d.Version) {
+					v.AffectedDependents++
+					exposed[d.PkgID] = true
+				}
+			}
+			if v.AffectedDependents > 0 {
+				dg.Pkgs[i].Vulns = append(dg.Pkgs[i].Vulns, v)
+			}
+		}
+		var reach float64
+		for _, id := range closure(dg, exposed, false) {
+			reach += dg.Pkgs[idx[id]].Rank
+		}
+		if total > 0 {
+			dg.Pkgs[i].VulnerableReach = reach / total
+		}
+	}
+}
+
+// closure returns the modules reachable from the start modules (included) following the dependency
+// edges upstream or downstream.
+func closure(dg dgraph.Graph, start map[uint32]bool, upstream bool) []uint32 {
+	seen := map[uint32]bool{}
+	var q, res []uint32
+	for id := range start {
+		seen[id] = true
+		q = append(q, id)
+	}
+	for len(q) > 0 {
+		c := q[0]
+		q = q[1:]
+		res = append(res, c)
+		for _, d := range dg.Deps[c] {
+			if d.Upstream == upstream && !seen[d.PkgID] {
+				seen[d.PkgID] = true
+				q = append(q, d.PkgID)
+			}
+		}
+	}
+	return res
+}
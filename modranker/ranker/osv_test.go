@@ -0,0 +1,53 @@
+package ranker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAffects(t *testing.T) {
+	const (
+		fixed        = `{"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]}`
+		lastAffected = `{"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"last_affected": "1.1.0"}]}]}`
+		twoRanges    = `{"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"fixed": "1.1.0"}, {"introduced": "1.5.0"}, {"fixed": "1.6.0"}]}]}`
+		versions     = `{"versions": ["1.3.0", "v2.0.0+incompatible"], "ranges": [{"type": "GIT", "events": [{"introduced": "0"}]}]}`
+	)
+	tests := []struct {
+		name     string
+		affected string
+		version  string
+		want     bool
+	}{
+		{"before fixed", fixed, "v1.1.0", true},
+		{"fixed", fixed, "v1.2.0", false},
+		{"after fixed", fixed, "v1.3.0", false},
+		{"without v prefix", fixed, "1.1.0", true},
+		{"pseudo-version before fixed", fixed, "v1.2.0-0.20200101000000-abcdefabcdef", true},
+		{"pseudo-version after fixed", fixed, "v1.2.1-0.20200101000000-abcdefabcdef", false},
+		{"untagged pseudo-version", fixed, "v0.0.0-20190101000000-abcdefabcdef", true},
+		{"invalid version", fixed, "master", false},
+		{"before introduced", lastAffected, "v0.9.0", false},
+		{"introduced", lastAffected, "v1.0.0", true},
+		{"last affected", lastAffected, "v1.1.0", true},
+		{"after last affected", lastAffected, "v1.1.1", false},
+		{"pseudo-version after last affected", lastAffected, "v1.1.1-0.20200101000000-abcdefabcdef", false},
+		{"first range", twoRanges, "v1.0.5", true},
+		{"between ranges", twoRanges, "v1.2.0", false},
+		{"second range", twoRanges, "v1.5.3", true},
+		{"after ranges", twoRanges, "v1.6.0", false},
+		{"listed version", versions, "v1.3.0", true},
+		{"listed incompatible version", versions, "v2.0.0+incompatible", true},
+		{"unlisted version, git range", versions, "v1.3.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a osvAffected
+			if err := json.Unmarshal([]byte(tt.affected), &a); err != nil {
+				t.Fatal(err)
+			}
+			if got := a.affects(tt.version); got != tt.want {
+				t.Errorf("affects(%s) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package ranker
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+type ownerRank struct {
+	Owner string `json:"owner"`
+	// Rank is the owner's share of the total rank of the modules.
+	Rank    float64  `json:"rank"`
+	Modules int      `json:"modules"`
+	Repos   int      `json:"repos"`
+	Stars   int      `json:"stars"`
+	Top     []string `json:"top"` // best ranked modules
+}
+
+// rankOwners aggregates the rank of the modules by the owner of their repository and returns the
+// owners in the decreasing order of their rank share with their k best ranked modules.
+func rankOwners(dg dgraph.Graph, k int) []ownerRank {
+	var total float64
+	for _, p := range dg.Pkgs {
+		total += p.Rank
+	}
+	byOwner := map[string]*ownerRank{}
+	var owners []*ownerRank
+	repos := map[string]bool{}
+	for _, p := range dg.Pkgs {
+		o := owner(p)
+		or, ok := byOwner[o]
+		if !ok {
+			or = &ownerRank{Owner: o, Top: []string{}}
+			byOwner[o] = or
+			owners = append(owners, or)
+		}
+		if total > 0 {
+			or.Rank += p.Rank / total
+		}
+		or.Modules++
+		// dg.Pkgs is ordered by rank
+		if len(or.Top) < k {
+			or.Top = append(or.Top, p.ModuleName)
+		}
+		if p.RepoName != "" && !repos[p.RepoName] {
+			repos[p.RepoName] = true
+			or.Repos++
+			or.Stars += p.Stars
+		}
+	}
+	sort.SliceStable(owners, func(i, j int) bool { return owners[i].Rank > owners[j].Rank })
+	res := make([]ownerRank, len(owners))
+	for i, or := range owners {
+		res[i] = *or
+	}
+	return res
+}
+
+func ownersCmd(args []string) {
+	fl := flag.NewFlagSet("owners", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	n := fl.Int("n", 100, "number of owners to list, 0 lists all")
+	k := fl.Int("k", 3, "number of top modules listed for every owner")
+	format := fl.String("f", "csv", "output format: csv, tsv, md or json")
+	fl.Parse(args)
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	owners := rankOwners(dg, *k)
+	if *n > 0 && len(owners) > *n {
+		owners = owners[:*n]
+	}
+	if *format == "json" {
+		if err = json.NewEncoder(os.Stdout).Encode(owners); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	tw, err := newTableWriter(os.Stdout, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = tw.Write([]string{posColumn, "owner", "rank", "modules", "repos", "stars", "top"}); err != nil {
+		log.Fatal(err)
+	}
+	for i, o := range owners {
+		row := []string{strconv.Itoa(i), o.Owner, strconv.FormatFloat(o.Rank, 'g', -1, 64), strconv.Itoa(o.Modules),
+			strconv.Itoa(o.Repos), strconv.Itoa(o.Stars), strings.Join(o.Top, ",")}
+		if err = tw.Write(row); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = tw.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package ranker
+
+import (
+	"log"
+	"math"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// personalizedRank computes the weighted PageRank of the nodes of dg like pagerank.Graph.Rank does,
+// but teleports (and redistributes the rank of the nodes without dependencies) only to the seeds.
+// The rank of the nodes whose dependencies all weigh 0, e.g. seeds without stars, is split
+// equally among their dependencies, otherwise it would flow back to the seeds.
+func personalizedRank(dg dgraph.Graph, seeds []uint32, α, ε float64, callback func(id uint32, rank float64)) {
+	out := map[uint32]float64{}
+	uniform := map[uint32]bool{}
+	for s, deps := range dg.Deps {
+		var o float64
+		var n int
+		for _, d := range deps {
+			if d.Upstream {
+				o += d.Weight
+				n++
+			}
+		}
+		if o == 0 && n > 0 {
+			o = float64(n)
+			uniform[s] = true
+		}
+		out[s] = o
+	}
+	tele := map[uint32]float64{}
+	for _, s := range seeds {
+		if _, ok := out[s]; ok {
+			tele[s] = 1
+		}
+	}
+	if len(tele) == 0 {
+		return
+	}
+	for s := range tele {
+		tele[s] /= float64(len(tele))
+	}
+
+	rank := map[uint32]float64{}
+	for s, v := range tele {
+		rank[s] = v
+	}
+	Δ := 1.0
+	for Δ > ε {
+		var leak float64
+		for s, o := range out {
+			if o == 0 {
+				leak += rank[s]
+			}
+		}
+		next := make(map[uint32]float64, len(out))
+		for s, o := range out {
+			if o == 0 || rank[s] == 0 {
+				continue
+			}
+			for _, d := range dg.Deps[s] {
+				if !d.Upstream {
+					continue
+				}
+				w := d.Weight
+				if uniform[s] {
+					w = 1
+				}
+				next[d.PkgID] += α * rank[s] * w / o
+			}
+		}
+		for s, v := range tele {
+			next[s] += (α*leak + 1 - α) * v
+		}
+		Δ = 0
+		for s := range out {
+			Δ += math.Abs(next[s] - rank[s])
+		}
+		rank = next
+	}
+	for s := range out {
+		callback(s, rank[s])
+	}
+}
+
+// seedModules returns the ids of the listed modules and of the modules in the repos of the given owners.
+func seedModules(nodes map[string]uint32, modules []mod, names, owners []string) []uint32 {
+	var seeds []uint32
+	for _, n := range names {
+		if id, ok := nodes[n]; ok {
+			seeds = append(seeds, id)
+		} else {
+			log.Printf("seed module %s is not in the graph", n)
+		}
+	}
+	for _, o := range owners {
+		pref := "github.com/" + strings.ToLower(o) + "/"
+		for _, m := range modules {
+			if strings.HasPrefix(m.Repo+"/", pref) {
+				seeds = append(seeds, nodes[m.Path])
+			}
+		}
+	}
+	return seeds
+}
@@ -0,0 +1,91 @@
+package ranker
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// testGraph builds a dependency graph from the upstream edges, adding their downstream pairs.
+func testGraph(edges map[uint32][]dgraph.Dependency) dgraph.Graph {
+	dg := dgraph.Graph{Deps: map[uint32][]dgraph.Dependency{}}
+	for s, deps := range edges {
+		for _, d := range deps {
+			d.Upstream = true
+			dg.Deps[s] = append(dg.Deps[s], d)
+			dg.Deps[d.PkgID] = append(dg.Deps[d.PkgID], dgraph.Dependency{PkgID: s, Weight: d.Weight})
+		}
+	}
+	return dg
+}
+
+func TestPersonalizedRank(t *testing.T) {
+	const s, a, b, c = 1, 2, 3, 4
+	tests := []struct {
+		name  string
+		edges map[uint32][]dgraph.Dependency
+		want  map[uint32]bool // nodes which must get some rank
+		equal []uint32        // nodes which must get the same rank
+	}{
+		{
+			name: "weighted",
+			edges: map[uint32][]dgraph.Dependency{
+				s: {{PkgID: a, Weight: 3}, {PkgID: b, Weight: 1}},
+				a: {{PkgID: c, Weight: 1}},
+			},
+			want: map[uint32]bool{a: true, b: true, c: true},
+		},
+		{
+			name: "zero weighted seed",
+			edges: map[uint32][]dgraph.Dependency{
+				s: {{PkgID: a}, {PkgID: b}},
+				a: {{PkgID: c}},
+			},
+			want:  map[uint32]bool{a: true, b: true, c: true},
+			equal: []uint32{a, b},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks := map[uint32]float64{}
+			personalizedRank(testGraph(tt.edges), []uint32{s}, 0.85, 1e-9, func(id uint32, r float64) {
+				ranks[id] = r
+			})
+			var sum float64
+			for _, r := range ranks {
+				sum += r
+			}
+			if math.Abs(sum-1) > 1e-6 {
+				t.Errorf("sum of the ranks is %f, want 1", sum)
+			}
+			for id := range tt.want {
+				if ranks[id] <= 0 {
+					t.Errorf("rank of %d is %f, want > 0", id, ranks[id])
+				}
+			}
+			for _, id := range tt.equal {
+				if math.Abs(ranks[id]-ranks[tt.equal[0]]) > 1e-6 {
+					t.Errorf("rank of %d is %f, want %f", id, ranks[id], ranks[tt.equal[0]])
+				}
+			}
+		})
+	}
+}
+
+func TestScoreRanksSkipsSeeds(t *testing.T) {
+	ps := []dgraph.Pkg{{Rank: 1, Seed: true}}
+	for i := 1; i <= 20; i++ {
+		ps = append(ps, dgraph.Pkg{Rank: 1 / float64(i), PRank: i})
+	}
+	scoreRanks(ps, 0.85)
+	if ps[0].Tier != "" {
+		t.Errorf("seed got tier %q", ps[0].Tier)
+	}
+	// the top 2 of the 20 ranked modules are in the top 10%
+	for i, want := range []string{"", "top 10%", "top 10%", ""} {
+		if ps[i].Tier != want {
+			t.Errorf("tier of position %d is %q, want %q", i, ps[i].Tier, want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package ranker
+
+import (
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+const (
+	sameOwnerPenalty = "same-owner"
+	ownerCapPenalty  = "owner-cap"
+	starBurstPenalty = "star-burst"
+)
+
+// antiGaming reduces the weight of the dependencies which can be used to boost a module
+// artificially, the zero value applies no penalty.
+type antiGaming struct {
+	// sameOwner multiplies the weight of the dependencies between the repos of the same owner.
+	sameOwner float64
+	// ownerCap is the largest share of a module's inbound weight the repos of an owner can contribute.
+	ownerCap float64
+	// burstRate is the number of stars per day since its creation above which a repo's stars are
+	// suspicious, the weight of its dependencies is multiplied by burstWeight.
+	burstRate, burstWeight float64
+	// now is the time the star rates are computed at.
+	now time.Time
+}
+
+func (ag antiGaming) enabled() bool {
+	return ag.sameOwner != 1 || ag.ownerCap > 0 || ag.burstRate > 0
+}
+
+// penalty is a reduction of the weight of the dependency of the From module on the To module.
+type penalty struct {
+	Kind             string
+	From, To         string
+	Weight, Weighted float64
+}
+
+// starsPerDay returns the number of stars the repository got per day since its creation.
+func starsPerDay(r github.Repository, now time.Time) float64 {
+	if r.CreatedAt == nil {
+		return 0
+	}
+	days := now.Sub(r.CreatedAt.Time).Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	return float64(r.GetStargazersCount()) / days
+}
+
+// setWeight sets the weight of the edge of s depending on d on both of its ends.
+func setWeight(dg dgraph.Graph, s, d uint32, w float64) {
+	for i, e := range dg.Deps[s] {
+		if e.PkgID == d && e.Upstream {
+			dg.Deps[s][i].Weight = w
+		}
+	}
+	for i, e := range dg.Deps[d] {
+		if e.PkgID == s && !e.Upstream {
+			dg.Deps[d][i].Weight = w
+		}
+	}
+}
+
+// apply penalizes the dependencies of dg, m2r maps the downloaded modules to their repos and
+// nodeNames the ids of the modules to their paths.
+// The star burst penalties are applied first, then the same owner ones and the owner cap
+// is applied to the penalized weights. It returns the applied penalties.
+func (ag antiGaming) apply(dg dgraph.Graph, repos map[string]github.Repository, m2r map[string]string, nodeNames map[uint32]string) []penalty {
+	repoOf := func(id uint32) string {
+		if r, ok := m2r[nodeNames[id]]; ok {
+			return r
+		}
+		return nodeNames[id]
+	}
+	var ps []penalty
+	penalize := func(kind string, s, d uint32, w, nw float64) {
+		ps = append(ps, penalty{Kind: kind, From: nodeNames[s], To: nodeNames[d], Weight: w, Weighted: nw})
+		setWeight(dg, s, d, nw)
+	}
+	ids := sortedIDs(dg)
+	for _, s := range ids {
+		r, ok := repos[repoOf(s)]
+		burst := ok && ag.burstRate > 0 && starsPerDay(r, ag.now) > ag.burstRate
+		for _, e := range dg.Deps[s] {
+			if !e.Upstream {
+				continue
+			}
+			w := e.Weight
+			if burst {
+				penalize(starBurstPenalty, s, e.PkgID, w, w*ag.burstWeight)
+				w *= ag.burstWeight
+			}
+			if ag.sameOwner != 1 && ownerOf(repoOf(s)) == ownerOf(repoOf(e.PkgID)) {
+				penalize(sameOwnerPenalty, s, e.PkgID, w, w*ag.sameOwner)
+			}
+		}
+	}
+	if ag.ownerCap <= 0 {
+		return ps
+	}
+	for _, d := range ids {
+		perOwner := map[string]float64{}
+		for _, e := range dg.Deps[d] {
+			if !e.Upstream {
+				perOwner[ownerOf(repoOf(e.PkgID))] += e.Weight
+			}
+		}
+		x, ok := capWeight(perOwner, ag.ownerCap)
+		if !ok {
+			continue
+		}
+		for _, e := range dg.Deps[d] {
+			o := ownerOf(repoOf(e.PkgID))
+			if e.Upstream || perOwner[o] <= x {
+				continue
+			}
+			penalize(ownerCapPenalty, e.PkgID, d, e.Weight, e.Weight*x/perOwner[o])
+		}
+	}
+	return ps
+}
+
+// capWeight returns the weight the owners over the cap have to be reduced to, so that each of them
+// contributes the cap share of the reduced total, and whether any owner is over the cap.
+// When the weights of the owners over the cap are reduced to x, the rest keeps its weight and
+// x = cap·(rest + n·x) for the n owners over the cap, that is x = cap·rest/(1-n·cap). Reducing
+// the weights lowers the total, so the owners are capped until no other one gets over x.
+// When no x meets the cap (too few owners for the cap), the owners over it are reduced to
+// the weight of the smallest of them, evening their shares.
+func capWeight(perOwner map[string]float64, cap float64) (float64, bool) {
+	capped := map[string]bool{}
+	for {
+		var rest float64
+		for o, w := range perOwner {
+			if !capped[o] {
+				rest += w
+			}
+		}
+		n := float64(len(capped))
+		if n > 0 && (rest == 0 || n*cap >= 1) {
+			x, max := math.Inf(1), 0.0
+			for o := range capped {
+				x, max = math.Min(x, perOwner[o]), math.Max(max, perOwner[o])
+			}
+			return x, x < max
+		}
+		x := cap * rest / (1 - n*cap)
+		grew := false
+		for o, w := range perOwner {
+			if !capped[o] && w > x {
+				capped[o] = true
+				grew = true
+			}
+		}
+		if !grew {
+			return x, n > 0
+		}
+	}
+}
+
+// writePenaltyReport writes the applied penalties ordered by the penalized module.
+func writePenaltyReport(fn, format string, ps []penalty) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = writePenalties(f, format, ps)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writePenalties(w io.Writer, format string, ps []penalty) error {
+	tw, err := newTableWriter(w, format)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].To < ps[j].To })
+	if err = tw.Write([]string{"module", "dependent", "penalty", "weight", "penalized_weight"}); err != nil {
+		return err
+	}
+	for _, p := range ps {
+		row := []string{p.To, p.From, p.Kind, strconv.FormatFloat(p.Weight, 'g', -1, 64), strconv.FormatFloat(p.Weighted, 'g', -1, 64)}
+		if err = tw.Write(row); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
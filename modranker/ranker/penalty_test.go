@@ -0,0 +1,42 @@
+package ranker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCapWeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		perOwner map[string]float64
+		cap      float64
+		capped   bool
+		// share is the largest share of an owner after capping
+		share float64
+	}{
+		{"under the cap", map[string]float64{"a": 50, "b": 50}, 0.5, false, 0.5},
+		{"one owner over", map[string]float64{"a": 90, "b": 10}, 0.5, true, 0.5},
+		{"one owner over a lower cap", map[string]float64{"a": 80, "b": 5, "c": 5, "d": 5, "e": 5, "f": 5}, 0.2, true, 0.2},
+		{"capping makes another owner over", map[string]float64{"a": 60, "b": 30, "c": 5, "d": 5}, 0.3, true, 0.3},
+		{"cap not attainable", map[string]float64{"a": 40, "b": 40, "c": 20}, 0.3, true, 1.0 / 3},
+		{"single owner", map[string]float64{"a": 10}, 0.5, false, 1},
+	}
+	for _, tt := range tests {
+		x, capped := capWeight(tt.perOwner, tt.cap)
+		if capped != tt.capped {
+			t.Errorf("%s: got capped %v, want %v", tt.name, capped, tt.capped)
+			continue
+		}
+		var total, max float64
+		for _, w := range tt.perOwner {
+			if capped && w > x {
+				w = x
+			}
+			total += w
+			max = math.Max(max, w)
+		}
+		if share := max / total; math.Abs(share-tt.share) > 1e-9 {
+			t.Errorf("%s: got largest share %v after capping to %v, want %v", tt.name, share, x, tt.share)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package ranker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alixaxel/pagerank"
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/config"
+	"github.com/hullarb/grank/modranker/dgraph"
+	"github.com/hullarb/grank/modranker/resolver"
+)
+
+type mod struct {
+	Repo       string
+	Path       string
+	Dir        string
+	File       string // path of the go.mod relative to the download dir
+	DirectDeps []string
+	Internal   map[string]bool   // dependencies which are modules of the same repo or workspace
+	Alternates []string          // other go.mod files declaring the same module
+	Workspace  string            // go.work file using the module
+	Kinds      map[string]string // kind of the dependencies imported by the module's files
+	Versions   map[string]string // required versions of the direct dependencies
+}
+
+// rankRun is the state of a ranking: its options, the ids of the modules and what is known of
+// their repos. Main starts a new one on every call.
+type rankRun struct {
+	verbose         bool
+	downloadDir     string
+	excludeInternal bool
+	excludeTest     bool
+	excludeTool     bool
+	halfLife        time.Duration
+	// unknownWeight is the floor of the modules whose repo is unknown, without it their
+	// dependencies would contribute nothing
+	unknownWeight float64
+	staleAfter    time.Duration
+	now           time.Time
+
+	nodes     map[string]uint32
+	nodeNames map[uint32]string
+	refs      map[uint32]int
+	w         map[string]int
+	weights   map[string]float64
+	starOrd   map[string]int
+}
+
+func newRankRun() *rankRun {
+	return &rankRun{
+		downloadDir:   "repos/",
+		halfLife:      defaultHalfLife,
+		unknownWeight: defaultUnknownWeight,
+		staleAfter:    defaultStaleAfter,
+		now:           time.Now(),
+		nodes:         make(map[string]uint32),
+		nodeNames:     make(map[uint32]string),
+		refs:          make(map[uint32]int),
+		w:             make(map[string]int),
+		weights:       make(map[string]float64),
+		starOrd:       make(map[string]int),
+	}
+}
+
+// Main runs modranker with the command line arguments, without the program name.
+func Main(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			diffCmd(args[1:])
+			return
+		case "serve":
+			serveCmd(args[1:])
+			return
+		case "convert":
+			convertCmd(args[1:])
+			return
+		case "export":
+			exportCmd(args[1:])
+			return
+		case "badges":
+			badgesCmd(args[1:])
+			return
+		case "explain":
+			explainCmd(args[1:])
+			return
+		case "dependents":
+			dependentsCmd(args[1:])
+			return
+		case "owners":
+			ownersCmd(args[1:])
+			return
+		}
+	}
+
+	rr := newRankRun()
+	fl := flag.NewFlagSet("modranker", flag.ExitOnError)
+	rf := fl.String("r", "", "repos json file (produced by lsrepo)")
+	of := fl.String("o", "", "output dependency graph file name, a .dgb extension selects the binary format and .gz compresses it")
+	fl.StringVar(&rr.downloadDir, "d", rr.downloadDir, "directory containing the dowloaded github repos")
+	fl.BoolVar(&rr.verbose, "v", false, "verbose logs")
+	ws := fl.String("w", "stars", "edge weighting of the depending repos: stars, logstars, forks, uniform, activity or decay, "+
+		"'*' multiplies and '+' sums them, e.g. logstars*activity+uniform")
+	fl.DurationVar(&rr.halfLife, "half-life", rr.halfLife, "time since the last push which halves the activity weighting")
+	fl.Float64Var(&rr.unknownWeight, "unknown-weight", rr.unknownWeight, "edge weight of the modules whose repo is not in the repos json")
+	fl.BoolVar(&rr.excludeInternal, "exclude-internal", false, "exclude the dependencies between the modules of the same repo or workspace from the ranking")
+	fl.BoolVar(&rr.excludeTest, "no-test", false, "exclude the dependencies only imported by tests from the ranking")
+	fl.BoolVar(&rr.excludeTool, "no-tool", false, "exclude the dependencies only imported by tools build tagged files from the ranking")
+	sm := fl.String("seeds", "", "comma separated list of modules to personalize the ranking for")
+	so := fl.String("org", "", "comma separated list of github owners whose modules personalize the ranking")
+	cf := fl.String("csv", "", "output file of the ranking table, stdout by default")
+	tf := fl.String("csv-format", "csv", "format of the ranking table: csv, tsv or md")
+	cols := fl.String("columns", defaultColumns, "comma separated columns of the ranking table: pos or any field name of the dependency graph modules")
+	tms := fl.Int("topic-min-size", 0, "rank the modules of the topics having at least this many modules, written as separate sections of the ranking table, 0 disables it")
+	na := fl.Int("alternatives", 0, "number of alternatives listed for every module, 0 disables finding them")
+	ag := antiGaming{now: rr.now}
+	fl.Float64Var(&ag.sameOwner, "same-owner-weight", 1, "multiplier of the weight of the dependencies between the repos of the same owner")
+	fl.Float64Var(&ag.ownerCap, "owner-cap", 0, "largest share of a module's inbound weight the repos of a single owner can contribute, 0 means no cap")
+	fl.Float64Var(&ag.burstRate, "burst-rate", 0, "stars per day since creation above which the stars of a repo are suspicious, 0 disables the detection")
+	fl.Float64Var(&ag.burstWeight, "burst-weight", 0.1, "multiplier of the weight of the dependencies of the repos with suspicious stars")
+	pr := fl.String("penalty-report", "", "output file of the report of the anti-gaming penalties applied to the dependencies, in the -csv-format")
+	cc := fl.Bool("collapse-cycles", false, "rank the modules of every dependency cycle as a single node, splitting its rank by their outside dependents")
+	cr := fl.String("cycle-report", "", "output file of the report of the dependency cycles with the rank share they hold, in the -csv-format")
+	cms := fl.Int("cluster-min-size", 3, "minimum size of the module communities reported as clusters")
+	osvDir := fl.String("osv", "", "directory of OSV vulnerability records (e.g. the Go vulndb export) to annotate the modules with")
+	fl.DurationVar(&rr.staleAfter, "stale", rr.staleAfter, "time since the last push after which a repository is considered unmaintained")
+	hr := fl.String("health-report", "", "output file of the dependency health metrics of the modules, in the -csv-format")
+	lr := fl.String("license-report", "", "output file of the report of the modules depending on copyleft or unlicensed modules")
+	sq := fl.String("sqlite", "", "SQLite database file to export the repos, modules, dependencies and ranks into")
+	sd := fl.String("snapshots", "", "directory where a dated copy of the dependency graph is stored and indexed")
+	α := fl.Float64("alpha", 0.85, "damping factor, the probability of following a dependency instead of teleporting")
+	ε := fl.Float64("tolerance", 0.0001, "convergence tolerance of the ranking, smaller is more exact but slower")
+	config.Flag(fl)
+	cfg, err := config.FromArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = rankFlags(cfg).Set(fl); err != nil {
+		log.Fatal(err)
+	}
+	fl.Parse(args)
+
+	wf, err := rr.parseWeighter(*ws)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err = pkgColumns(splitList(*cols)); err != nil {
+		log.Fatal(err)
+	}
+	if _, err = newTableWriter(ioutil.Discard, *tf); err != nil {
+		log.Fatal(err)
+	}
+	if *sq != "" && !sqliteSupported {
+		log.Fatal("-sqlite needs modranker built with cgo and the sqlite build tag: go build -tags sqlite")
+	}
+
+	inp, err := os.Open(*rf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer inp.Close()
+	var repos []github.Repository
+	err = json.NewDecoder(inp).Decode(&repos)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reposByName := map[string]github.Repository{}
+	var ord int
+	for _, r := range repos {
+		rn := "github.com/" + strings.ToLower(r.GetFullName())
+		rr.w[rn] = r.GetStargazersCount()
+		rr.weights[rn] = wf(&r)
+		if _, ok := rr.starOrd[rn]; !ok {
+			rr.starOrd[rn] = ord
+			ord++
+		}
+		reposByName[rn] = r
+	}
+
+	modules, err := rr.findModules()
+	if err != nil {
+		log.Printf("failed to list modules in download dir %s: %v", rr.downloadDir, err)
+	}
+
+	graph := pagerank.NewGraph()
+	var dg dgraph.Graph
+	dg.Deps = make(map[uint32][]dgraph.Dependency)
+	m2r := map[string]string{}
+	modsByPath := map[string]mod{}
+	for _, m := range modules {
+		s := rr.nodeID(m.Path)
+		m2r[m.Path] = m.Repo
+		modsByPath[m.Path] = m
+		for _, dn := range m.DirectDeps {
+			d := rr.nodeID(dn)
+			// a dependent of the module may also be its dependency, only a dependency is a duplicate
+			if dg.DependsOn(s, d) {
+				log.Printf("duplicate: %s, %s", m.Path, dn)
+				continue
+			}
+			internal := m.Internal[dn]
+			if internal && rr.excludeInternal {
+				if rr.verbose {
+					log.Printf("excluding internal: %s -> %s", m.Path, dn)
+				}
+				continue
+			}
+			kind := m.Kinds[dn]
+			if kind == testDep && rr.excludeTest || kind == toolDep && rr.excludeTool {
+				if rr.verbose {
+					log.Printf("excluding %s: %s -> %s", kind, m.Path, dn)
+				}
+				continue
+			}
+			rr.refs[d]++
+			if rr.verbose {
+				log.Printf("G: %s -> %s", m.Path, dn)
+			}
+			ew := rr.edgeWeight(m.Repo)
+			graph.Link(s, d, ew)
+			dep := dgraph.Dependency{PkgID: d, Upstream: true, Weight: ew, Internal: internal, Kind: kind, Version: m.Versions[dn]}
+			dg.Deps[s] = append(dg.Deps[s], dep)
+			dep.PkgID, dep.Upstream = s, false
+			dg.Deps[d] = append(dg.Deps[d], dep)
+		}
+	}
+	probabilityOfFollowingALink := *α // The bigger the number, less probability we have to teleport to some random link
+	tolerance := *ε                   // the smaller the number, the more exact the result will be but more CPU cycles will be neede
+
+	if ag.enabled() {
+		penalties := ag.apply(dg, reposByName, m2r, rr.nodeNames)
+		log.Printf("%d dependency weights penalized", len(penalties))
+		graph = linkGraph(dg)
+		if *pr != "" {
+			if err = writePenaltyReport(*pr, *tf, penalties); err != nil {
+				log.Fatalf("failed to write the penalty report: %v", err)
+			}
+		}
+	}
+	sccs := cycles(dg)
+	log.Printf("%d dependency cycles found", len(sccs))
+	rankGraph := dg
+	seeds := seedModules(rr.nodes, modules, splitList(*sm), splitList(*so))
+	isSeed := map[uint32]bool{}
+	for _, s := range seeds {
+		isSeed[s] = true
+	}
+	if *cc {
+		var rep map[uint32]uint32
+		rankGraph, rep = collapseCycles(dg, sccs)
+		graph = linkGraph(rankGraph)
+		for i, s := range seeds {
+			if r, ok := rep[s]; ok {
+				seeds[i] = r
+			}
+		}
+	}
+	rankFn := graph.Rank
+	if *sm != "" || *so != "" {
+		if len(seeds) == 0 {
+			log.Fatal("none of the seed modules are in the graph")
+		}
+		rankFn = func(α, ε float64, callback func(id uint32, rank float64)) {
+			personalizedRank(rankGraph, seeds, α, ε, callback)
+		}
+	}
+	if *cc {
+		collapsed := rankFn
+		rankFn = func(α, ε float64, callback func(id uint32, rank float64)) {
+			collapsed(α, ε, expandCycles(dg, sccs, callback))
+		}
+	}
+
+	rankFn(probabilityOfFollowingALink, tolerance, func(id uint32, rank float64) {
+		name := rr.nodeNames[id]
+		rn := m2r[name]
+		if rn != "" && rn != name {
+			name = fmt.Sprintf("%s (%s)", name, rn)
+		} else if rn == "" && strings.HasPrefix(name, "github.com") {
+			rn = name
+		}
+		dg.Pkgs = append(dg.Pkgs, dgraph.Pkg{Name: name, ModuleName: rr.nodeNames[id], RepoName: rn, Rank: rank})
+	})
+	sort.Slice(dg.Pkgs, func(i, j int) bool {
+		return dg.Pkgs[i].Rank > dg.Pkgs[j].Rank
+	})
+	rank := 0
+	prev := -1.0
+	for i, r := range dg.Pkgs {
+		repo := reposByName[r.RepoName]
+		dg.Pkgs[i].ID = rr.nodes[r.ModuleName]
+		dg.Pkgs[i].SRank = rr.starOrd[r.RepoName]
+		dg.Pkgs[i].Stars = rr.w[r.RepoName]
+		dg.Pkgs[i].Imports = rr.refs[dg.Pkgs[i].ID]
+		if repo.Description != nil {
+			dg.Pkgs[i].Description = *repo.Description
+		}
+		dg.Pkgs[i].Topics = repo.Topics
+		dg.Pkgs[i].Seed = isSeed[dg.Pkgs[i].ID]
+		dg.Pkgs[i].Workspace = modsByPath[r.ModuleName].Workspace
+		dg.Pkgs[i].Alternates = modsByPath[r.ModuleName].Alternates
+		// the seeds are left out from the ranking, they would leave gaps in its positions
+		if dg.Pkgs[i].Seed {
+			continue
+		}
+		if r.Rank != prev {
+			rank++
+		}
+		prev = r.Rank
+		dg.Pkgs[i].PRank = rank
+	}
+
+	scoreRanks(dg.Pkgs, *α)
+	dg.Cycles = summarizeCycles(dg, sccs)
+	if *tms > 0 {
+		dg.TopicRankings = rankTopics(dg, *tms, probabilityOfFollowingALink, tolerance)
+		log.Printf("%d topics ranked", len(dg.TopicRankings))
+	}
+	if *na > 0 {
+		findAlternatives(dg, *na)
+	}
+	dg.Clusters = detectClusters(dg, *cms)
+	log.Printf("%d clusters found", len(dg.Clusters))
+	if *osvDir != "" {
+		vulns, err := loadOSV(*osvDir)
+		if err != nil {
+			log.Fatalf("failed to load OSV records from %s: %v", *osvDir, err)
+		}
+		annotateVulns(dg, vulns)
+	}
+	annotateLicenses(dg, rr.downloadDir, modules, reposByName)
+	rr.annotateHealth(dg, reposByName)
+	// the metrics of the transitive dependencies need a traversal per module, they are only
+	// computed when they are reported
+	var visitors []depVisitor
+	offending := map[uint32][]uint32{}
+	if *lr != "" || selected(splitList(*cols), licenseDepsColumns...) {
+		visitors = append(visitors, licenseVisitor(dg, offending))
+	}
+	if *hr != "" || selected(splitList(*cols), traversalHealthColumns...) {
+		visitors = append(visitors, healthVisitor(dg))
+	}
+	walkDeps(dg, visitors...)
+	if err = writeTable(*cf, *tf, splitList(*cols), dg); err != nil {
+		log.Fatalf("failed to write the ranking: %v", err)
+	}
+	err = dgraph.WriteFile(*of, dg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *cr != "" {
+		if err = writeCycleReport(*cr, *tf, dg.Cycles); err != nil {
+			log.Fatalf("failed to write the cycle report: %v", err)
+		}
+	}
+	if *hr != "" {
+		if err = writeHealthReport(*hr, *tf, dg); err != nil {
+			log.Fatalf("failed to write the health report: %v", err)
+		}
+	}
+	if *lr != "" {
+		if err = writeLicenseReport(*lr, *tf, dg, offending); err != nil {
+			log.Fatalf("failed to write the license report: %v", err)
+		}
+	}
+	if *sq != "" {
+		if err = exportSQLite(*sq, repos, modules, rr.nodes, dg); err != nil {
+			log.Fatalf("failed to export to %s: %v", *sq, err)
+		}
+	}
+	if *sd != "" {
+		s, err := storeSnapshot(*sd, dg, rr.now)
+		if err != nil {
+			log.Fatalf("failed to store snapshot: %v", err)
+		}
+		log.Printf("snapshot %s stored in %s", s.ID, *sd)
+	}
+}
+
+// rankFlags returns the values of the modranker flags set by the config.
+func rankFlags(cfg *config.Config) config.Flags {
+	f := config.Flags{}
+	r := cfg.Rank
+	f.String("r", cfg.Path(cfg.Repos))
+	f.String("d", cfg.Path(cfg.DownloadDir))
+	f.String("o", cfg.Path(r.Graph))
+	f.String("w", r.Weighting)
+	f.String("half-life", r.HalfLife)
+	f.Float("alpha", r.Alpha)
+	f.Float("tolerance", r.Tolerance)
+	f.Bool("exclude-internal", r.ExcludeInternal)
+	f.Bool("no-test", r.NoTest)
+	f.Bool("no-tool", r.NoTool)
+	f.List("seeds", r.Seeds)
+	f.List("org", r.Orgs)
+	f.String("csv", cfg.Path(r.Table))
+	f.String("csv-format", r.TableFormat)
+	f.String("columns", r.Columns)
+	f.String("sqlite", cfg.Path(r.SQLite))
+	f.String("snapshots", cfg.Path(r.Snapshots))
+	for n, v := range r.Flags {
+		if pathFlags[n] {
+			v = cfg.Path(v)
+		}
+		f[n] = v
+	}
+	return f
+}
+
+// pathFlags are the modranker flags taking a file or directory, set in the config they are
+// relative to its data directory like its typed paths.
+var pathFlags = map[string]bool{
+	"r": true, "d": true, "o": true, "csv": true, "sqlite": true, "snapshots": true, "osv": true,
+	"penalty-report": true, "cycle-report": true, "health-report": true, "license-report": true,
+}
+
+// inputFlags are the path flags of the files and directories read by the ranking, besides the
+// download directory.
+var inputFlags = []string{"r", "osv"}
+
+// Inputs returns the files and directories read by the ranking configured by cfg, besides the
+// download directory.
+func Inputs(cfg *config.Config) []string {
+	f := rankFlags(cfg)
+	var in []string
+	for _, n := range inputFlags {
+		if v := f[n]; v != "" {
+			in = append(in, v)
+		}
+	}
+	return in
+}
+
+// selected tells whether any of the columns is among the selected ones.
+func selected(cols []string, columns ...string) bool {
+	for _, c := range columns {
+		if contains(cols, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+func (rr *rankRun) nodeID(nn string) uint32 {
+	if id, ok := rr.nodes[nn]; ok {
+		return id
+	}
+	id := uint32(len(rr.nodes))
+	rr.nodes[nn] = id
+	rr.nodeNames[id] = nn
+	return id
+}
+
+func (rr *rankRun) findModules() ([]mod, error) {
+	var modules []mod
+	moduleFiles := map[string]int{}
+	workspaces := map[string]string{}
+	pref := rr.downloadDir
+	if pref[len(pref)-1] != '/' {
+		pref += "/"
+	}
+	err := filepath.WalkDir(rr.downloadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("prevent panic by handling failure accessing a path %q: %v\n", path, err)
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Base(path) {
+		case "go.work":
+			dirs, err := workspaceDirs(path)
+			if err != nil {
+				log.Printf("failed to parse work file %s: %v", path, err)
+				return nil
+			}
+			for _, wd := range dirs {
+				workspaces[wd] = path
+			}
+			return nil
+		case "go.mod":
+		default:
+			return nil
+		}
+		c, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Printf("failed to read mod file %s: %v", path, err)
+			return nil
+		}
+		m, err := parseMod(path, c)
+		if err != nil {
+			log.Printf("failed to parse mod file %s: %v", path, err)
+			return nil
+		}
+		if m.Module == nil {
+			log.Printf("nil module in %s", path)
+			return nil
+		}
+		pp := strings.Split(strings.Split(path, pref)[1], "/")
+		rd := strings.ToLower(strings.Join(pp[:3], "/"))
+		mp := m.Module.Mod.Path
+		var direct, nilC int
+		if !strings.HasPrefix(strings.ToLower(mp), rd) && !strings.HasPrefix(mp, "github.com") && strings.Contains(strings.Split(mp, "/")[0], ".") {
+			log.Printf("path for %s is %s, resolving", mp, rd)
+			repo, err := resolver.RepoRootForImportDynamic(mp, resolver.IgnoreMod)
+			if err != nil {
+				log.Printf("failed to resolve %s: %v", mp, err)
+				return nil
+			}
+			rp := strings.ReplaceAll(repo.Repo, "https://", "")
+			rp = strings.ReplaceAll(rp, ".git", "")
+			if strings.ToLower(rp) != rd {
+				log.Printf("repo for %s is %s while path is %s", mp, rp, rd)
+				return nil
+			}
+		} else if strings.HasPrefix(mp, "github.com") && !strings.HasPrefix(strings.ToLower(mp), rd) {
+			log.Printf("module with github path %s is not in expected folder %s", mp, path)
+			return nil
+		}
+		mod := mod{
+			Repo:     rd,
+			Path:     mp,
+			Dir:      filepath.Dir(path),
+			File:     strings.Join(pp, "/"),
+			Internal: map[string]bool{},
+			Versions: map[string]string{},
+		}
+		for _, r := range localReplaces(m) {
+			mod.Internal[r] = true
+		}
+		for _, r := range m.Require {
+			if r == nil {
+				nilC++
+				continue
+			}
+			if !r.Indirect {
+				direct++
+				mod.DirectDeps = append(mod.DirectDeps, r.Mod.Path)
+				mod.Versions[r.Mod.Path] = r.Mod.Version
+			}
+
+		}
+		if i, ok := moduleFiles[mp]; ok {
+			prev := modules[i]
+			log.Printf("found duplicate module file for %s in path %s prev: %s", mp, path, prev.File)
+			// the module closest to the root of its repository wins, the walk order breaks ties
+			if strings.Count(mod.File, "/") < strings.Count(prev.File, "/") {
+				mod.Alternates = append(prev.Alternates, prev.File)
+				modules[i] = mod
+			} else {
+				modules[i].Alternates = append(prev.Alternates, mod.File)
+			}
+			return nil
+		}
+		moduleFiles[mp] = len(modules)
+		modules = append(modules, mod)
+		return nil
+	})
+
+	repoModules := map[string]map[string]bool{}
+	for _, m := range modules {
+		if repoModules[m.Repo] == nil {
+			repoModules[m.Repo] = map[string]bool{}
+		}
+		repoModules[m.Repo][m.Path] = true
+	}
+	for i, m := range modules {
+		ws := workspaces[m.Dir]
+		for _, dp := range m.DirectDeps {
+			if repoModules[m.Repo][dp] {
+				m.Internal[dp] = true
+			} else if j, ok := moduleFiles[dp]; ok && ws != "" && workspaces[modules[j].Dir] == ws {
+				m.Internal[dp] = true
+			}
+		}
+		if ws != "" {
+			modules[i].Workspace = strings.TrimPrefix(ws, pref)
+		}
+		modules[i].Kinds = rr.cachedClassifyDeps(modules[i])
+	}
+	return modules, err
+}
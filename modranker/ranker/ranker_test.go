@@ -0,0 +1,33 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/config"
+)
+
+// The generic rank flags of the config override its typed fields.
+func TestRankFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		flag string
+		want string
+	}{
+		{"unset", config.Config{}, "w", ""},
+		{"typed", config.Config{Rank: config.Rank{Weighting: "logstars"}}, "w", "logstars"},
+		{"flags over typed", config.Config{Rank: config.Rank{Weighting: "logstars", Flags: map[string]string{"w": "uniform"}}}, "w", "uniform"},
+		{"data dir", config.Config{DataDir: "data", Repos: "repos.json"}, "r", "data/repos.json"},
+		{"path flag", config.Config{DataDir: "data", Rank: config.Rank{Flags: map[string]string{"health-report": "health.csv"}}}, "health-report", "data/health.csv"},
+		{"absolute path flag", config.Config{DataDir: "data", Rank: config.Rank{Flags: map[string]string{"osv": "/var/lib/vulndb"}}}, "osv", "/var/lib/vulndb"},
+		{"bool", config.Config{Rank: config.Rank{NoTest: true}}, "no-test", "true"},
+		{"list", config.Config{Rank: config.Rank{Seeds: []string{"a", "b"}}}, "seeds", "a,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rankFlags(&tt.cfg)[tt.flag]; got != tt.want {
+				t.Errorf("got %s=%q, want %q", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package ranker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// graphIndex answers queries over a dependency graph loaded into memory.
+type graphIndex struct {
+	dg     dgraph.Graph
+	byID   map[uint32]int
+	byName map[string]int
+	words  map[string][]int
+}
+
+func newGraphIndex(dg dgraph.Graph) *graphIndex {
+	gi := &graphIndex{
+		dg:     dg,
+		byID:   make(map[uint32]int, len(dg.Pkgs)),
+		byName: make(map[string]int, len(dg.Pkgs)),
+		words:  map[string][]int{},
+	}
+	for i, p := range dg.Pkgs {
+		gi.byID[p.ID] = i
+		gi.byName[p.ModuleName] = i
+		seen := map[string]bool{}
+		for _, w := range words(p.ModuleName + " " + p.Description + " " + strings.Join(p.Topics, " ")) {
+			if !seen[w] {
+				seen[w] = true
+				gi.words[w] = append(gi.words[w], i)
+			}
+		}
+	}
+	return gi
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (gi *graphIndex) pkg(name string) (dgraph.Pkg, bool) {
+	i, ok := gi.byName[name]
+	if !ok {
+		return dgraph.Pkg{}, false
+	}
+	return gi.dg.Pkgs[i], true
+}
+
+func (gi *graphIndex) pkgByID(id uint32) (dgraph.Pkg, bool) {
+	i, ok := gi.byID[id]
+	if !ok {
+		return dgraph.Pkg{}, false
+	}
+	return gi.dg.Pkgs[i], true
+}
+
+type pkgFilter struct {
+	topic              string
+	minStars, maxStars int
+	host               string
+	cluster            int
+}
+
+func (f pkgFilter) match(p dgraph.Pkg) bool {
+	if f.topic != "" && !contains(p.Topics, f.topic) {
+		return false
+	}
+	if p.Stars < f.minStars || f.maxStars > 0 && p.Stars > f.maxStars {
+		return false
+	}
+	if f.cluster != 0 && p.Cluster != f.cluster {
+		return false
+	}
+	return f.host == "" || strings.SplitN(p.ModuleName, "/", 2)[0] == f.host
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// top returns the n best ranked modules matching the filter, dg.Pkgs is ordered by rank.
+func (gi *graphIndex) top(n int, f pkgFilter) []dgraph.Pkg {
+	ps := []dgraph.Pkg{}
+	for _, p := range gi.dg.Pkgs {
+		if len(ps) >= n {
+			break
+		}
+		if f.match(p) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+type moduleDetail struct {
+	dgraph.Pkg
+	Upstream   []neighbour `json:"upstream"`
+	Downstream []neighbour `json:"downstream"`
+}
+
+type neighbour struct {
+	ID     uint32  `json:"id"`
+	Name   string  `json:"module_name"`
+	Rank   float64 `json:"rank"`
+	PRank  int     `json:"prank"`
+	Stars  int     `json:"stars"`
+	Weight float64 `json:"w,omitempty"`
+	Kind   string  `json:"kind,omitempty"`
+}
+
+func (gi *graphIndex) detail(p dgraph.Pkg) moduleDetail {
+	md := moduleDetail{Pkg: p, Upstream: []neighbour{}, Downstream: []neighbour{}}
+	for _, d := range gi.dg.Deps[p.ID] {
+		i, ok := gi.byID[d.PkgID]
+		if !ok {
+			continue
+		}
+		n := gi.dg.Pkgs[i]
+		nb := neighbour{ID: n.ID, Name: n.ModuleName, Rank: n.Rank, PRank: n.PRank, Stars: n.Stars, Weight: d.Weight, Kind: d.Kind}
+		if d.Upstream {
+			md.Upstream = append(md.Upstream, nb)
+		} else {
+			md.Downstream = append(md.Downstream, nb)
+		}
+	}
+	for _, l := range [][]neighbour{md.Upstream, md.Downstream} {
+		l := l
+		sort.Slice(l, func(i, j int) bool { return l[i].Rank > l[j].Rank })
+	}
+	return md
+}
+
+// path returns the shortest chain of dependencies leading from the module from to the module to.
+func (gi *graphIndex) path(from, to uint32) []uint32 {
+	prev := map[uint32]uint32{from: from}
+	q := []uint32{from}
+	for len(q) > 0 {
+		c := q[0]
+		q = q[1:]
+		if c == to {
+			var p []uint32
+			for ; c != from; c = prev[c] {
+				p = append(p, c)
+			}
+			p = append(p, from)
+			for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+				p[i], p[j] = p[j], p[i]
+			}
+			return p
+		}
+		for _, d := range gi.dg.Deps[c] {
+			if _, ok := prev[d.PkgID]; d.Upstream && !ok {
+				prev[d.PkgID] = c
+				q = append(q, d.PkgID)
+			}
+		}
+	}
+	return nil
+}
+
+// search returns the modules containing the most words of the query in their name, description
+// or topics, ties are broken by rank.
+func (gi *graphIndex) search(q string, n int) []dgraph.Pkg {
+	hits := map[int]int{}
+	for _, w := range words(q) {
+		for _, i := range gi.words[w] {
+			hits[i]++
+		}
+	}
+	res := make([]int, 0, len(hits))
+	for i := range hits {
+		res = append(res, i)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if hits[res[i]] != hits[res[j]] {
+			return hits[res[i]] > hits[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	ps := []dgraph.Pkg{}
+	for _, i := range res {
+		if len(ps) >= n {
+			break
+		}
+		ps = append(ps, gi.dg.Pkgs[i])
+	}
+	return ps
+}
+
+func serveCmd(args []string) {
+	fl := flag.NewFlagSet("serve", flag.ExitOnError)
+	gf := fl.String("g", "dg.json", "dependency graph file (produced by modranker)")
+	addr := fl.String("addr", ":8080", "address to listen on")
+	fl.Parse(args)
+
+	dg, err := dgraph.ReadFile(*gf)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", *gf, err)
+	}
+	log.Printf("loaded %d modules from %s, listening on %s", len(dg.Pkgs), *gf, *addr)
+	log.Fatal(http.ListenAndServe(*addr, newGraphIndex(dg).handler()))
+}
+
+func (gi *graphIndex) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/top", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := pkgFilter{topic: q.Get("topic"), host: q.Get("host")}
+		var err error
+		for _, p := range []struct {
+			name string
+			v    *int
+		}{{"min_stars", &f.minStars}, {"max_stars", &f.maxStars}, {"cluster", &f.cluster}} {
+			if *p.v, err = intParam(p.name, q.Get(p.name), 0); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		n, err := countParam(q.Get("n"), 100)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeResponse(w, http.StatusOK, gi.top(n, f))
+	})
+	mux.HandleFunc("/api/module", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := gi.pkg(r.URL.Query().Get("name"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown module")
+			return
+		}
+		writeResponse(w, http.StatusOK, gi.detail(p))
+	})
+	mux.HandleFunc("/api/path", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, ok := gi.pkg(q.Get("from"))
+		to, ok2 := gi.pkg(q.Get("to"))
+		if !ok || !ok2 {
+			writeError(w, http.StatusNotFound, "unknown module")
+			return
+		}
+		ps := []dgraph.Pkg{}
+		for _, id := range gi.path(from.ID, to.ID) {
+			p, ok := gi.pkgByID(id)
+			if !ok {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("unknown module id %d", id))
+				return
+			}
+			ps = append(ps, p)
+		}
+		writeResponse(w, http.StatusOK, ps)
+	})
+	mux.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		n, err := countParam(q.Get("n"), 20)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeResponse(w, http.StatusOK, gi.search(q.Get("q"), n))
+	})
+	mux.HandleFunc("/api/clusters", func(w http.ResponseWriter, r *http.Request) {
+		cs := gi.dg.Clusters
+		if cs == nil {
+			cs = []dgraph.Cluster{}
+		}
+		writeResponse(w, http.StatusOK, cs)
+	})
+	mux.HandleFunc("/badge/", gi.badgeHandler)
+	return mux
+}
+
+// intParam returns the integer value of the named parameter, def when it is missing.
+func intParam(name, v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, v)
+	}
+	return i, nil
+}
+
+// countParam returns the number of results requested by the parameter, def when it is missing.
+func countParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of results: %s", v)
+	}
+	return n, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeResponse(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,44 @@
+package ranker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestHandlerStatus(t *testing.T) {
+	dg := dgraph.Graph{
+		Pkgs: []dgraph.Pkg{{ID: 1, ModuleName: "a"}, {ID: 2, ModuleName: "b"}},
+		Deps: map[uint32][]dgraph.Dependency{
+			1: {{PkgID: 3, Upstream: true}},
+			3: {{PkgID: 2, Upstream: true}},
+		},
+	}
+	h := newGraphIndex(dg).handler()
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/api/top", http.StatusOK},
+		{"/api/top?n=1", http.StatusOK},
+		{"/api/top?n=-1", http.StatusBadRequest},
+		{"/api/top?n=x", http.StatusBadRequest},
+		{"/api/top?min_stars=10&cluster=1", http.StatusOK},
+		{"/api/top?min_stars=many", http.StatusBadRequest},
+		{"/api/top?max_stars=1.5", http.StatusBadRequest},
+		{"/api/top?cluster=x", http.StatusBadRequest},
+		{"/api/search?q=a&n=-5", http.StatusBadRequest},
+		{"/api/module?name=c", http.StatusNotFound},
+		// the path leads through the module 3 which is not in the graph
+		{"/api/path?from=a&to=b", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tt.url, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.url, rec.Code, tt.want)
+		}
+	}
+}
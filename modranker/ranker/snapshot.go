@@ -0,0 +1,95 @@
+package ranker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+const snapshotIndex = "index.json"
+
+type snapshot struct {
+	ID      string    `json:"id"`
+	File    string    `json:"file"`
+	Time    time.Time `json:"time"`
+	Modules int       `json:"modules"`
+}
+
+// readIndex returns the snapshots stored in dir, oldest first.
+func readIndex(dir string) ([]snapshot, error) {
+	c, err := ioutil.ReadFile(filepath.Join(dir, snapshotIndex))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx []snapshot
+	err = json.Unmarshal(c, &idx)
+	return idx, err
+}
+
+// storeSnapshot saves dg into dir as a new dated snapshot and adds it to the index of the directory.
+func storeSnapshot(dir string, dg dgraph.Graph, t time.Time) (snapshot, error) {
+	t = t.UTC()
+	s := snapshot{
+		ID:      t.Format("20060102T150405Z"),
+		Time:    t,
+		Modules: len(dg.Pkgs),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return s, err
+	}
+	idx, err := readIndex(dir)
+	if err != nil {
+		return s, fmt.Errorf("failed to read snapshot index: %v", err)
+	}
+	// the ids have second resolution, the snapshots of the same second get a suffix
+	ids := map[string]bool{}
+	for _, o := range idx {
+		ids[o.ID] = true
+	}
+	for n := 2; ids[s.ID]; n++ {
+		s.ID = fmt.Sprintf("%s-%d", t.Format("20060102T150405Z"), n)
+	}
+	s.File = "dg-" + s.ID + ".json"
+	if err = dgraph.WriteFile(filepath.Join(dir, s.File), dg); err != nil {
+		return s, err
+	}
+	idx = append(idx, s)
+	return s, writeJSON(filepath.Join(dir, snapshotIndex), idx)
+}
+
+// loadSnapshot reads a dependency graph either from a file or from the snapshot with the id in dir.
+func loadSnapshot(dir, ref string) (dgraph.Graph, error) {
+	if _, err := os.Stat(ref); err == nil || dir == "" {
+		return dgraph.ReadFile(ref)
+	}
+	idx, err := readIndex(dir)
+	if err != nil {
+		return dgraph.Graph{}, err
+	}
+	for _, s := range idx {
+		if s.ID == ref {
+			return dgraph.ReadFile(filepath.Join(dir, s.File))
+		}
+	}
+	return dgraph.Graph{}, fmt.Errorf("no snapshot %s in %s", ref, dir)
+}
+
+func writeJSON(fn string, v interface{}) error {
+	out, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(out).Encode(v)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
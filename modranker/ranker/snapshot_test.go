@@ -0,0 +1,52 @@
+package ranker
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestStoreSnapshotSameSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, want := range []string{"20240101T000000Z", "20240101T000000Z-2", "20240101T000000Z-3"} {
+		s, err := storeSnapshot(dir, dgraph.Graph{}, tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ID != want {
+			t.Errorf("got id %s, want %s", s.ID, want)
+		}
+	}
+	idx, err := readIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx) != 3 {
+		t.Errorf("got %d snapshots in the index, want 3", len(idx))
+	}
+}
+
+func TestDiffGraphsTop(t *testing.T) {
+	graph := func(names ...string) dgraph.Graph {
+		var g dgraph.Graph
+		for i, n := range names {
+			g.Pkgs = append(g.Pkgs, dgraph.Pkg{ModuleName: n, PRank: i + 1})
+		}
+		return g
+	}
+	d := diffGraphs(graph("a", "b", "c", "d", "e"), graph("b", "a", "c", "e", "d"), 2)
+	if len(d.Risers) != 1 || d.Risers[0].Module != "b" {
+		t.Errorf("got risers %v, want b", d.Risers)
+	}
+	if len(d.Fallers) != 1 || d.Fallers[0].Module != "a" {
+		t.Errorf("got fallers %v, want a", d.Fallers)
+	}
+}
@@ -0,0 +1,187 @@
+//go:build sqlite
+// +build sqlite
+
+package ranker
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/hullarb/grank/modranker/dgraph"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE repos (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	owner TEXT,
+	description TEXT,
+	stars INTEGER,
+	forks INTEGER,
+	open_issues INTEGER,
+	license TEXT,
+	fork INTEGER,
+	archived INTEGER,
+	default_branch TEXT,
+	created_at TIMESTAMP,
+	pushed_at TIMESTAMP,
+	updated_at TIMESTAMP
+);
+CREATE TABLE repo_topics (
+	repo_id INTEGER NOT NULL REFERENCES repos(id),
+	topic TEXT NOT NULL,
+	PRIMARY KEY (repo_id, topic)
+);
+CREATE TABLE modules (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL UNIQUE,
+	repo_id INTEGER REFERENCES repos(id),
+	mod_file TEXT,
+	workspace TEXT
+);
+CREATE TABLE module_alternates (
+	module_id INTEGER NOT NULL REFERENCES modules(id),
+	mod_file TEXT NOT NULL
+);
+CREATE TABLE dependencies (
+	module_id INTEGER NOT NULL REFERENCES modules(id),
+	dependency_id INTEGER NOT NULL REFERENCES modules(id),
+	version TEXT,
+	kind TEXT,
+	internal INTEGER,
+	weight REAL,
+	PRIMARY KEY (module_id, dependency_id)
+);
+CREATE TABLE ranks (
+	module_id INTEGER PRIMARY KEY REFERENCES modules(id),
+	rank REAL,
+	prank INTEGER,
+	grank INTEGER,
+	srank INTEGER,
+	imports INTEGER
+);
+CREATE INDEX dependencies_dependency ON dependencies(dependency_id);
+CREATE INDEX modules_repo ON modules(repo_id);
+`
+
+// sqliteSupported tells whether the SQLite driver is built in, it needs cgo and the sqlite build tag.
+const sqliteSupported = true
+
+// exportSQLite writes the repos, the modules found in them, the dependencies between the modules
+// and the computed ranks into a new SQLite database, nodes maps the module paths to their ids.
+func exportSQLite(fn string, repos []github.Repository, modules []mod, nodes map[string]uint32, dg dgraph.Graph) error {
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("failed to create schema: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	repoIDs := map[string]int64{}
+	for _, r := range repos {
+		rn := "github.com/" + strings.ToLower(r.GetFullName())
+		if _, ok := repoIDs[rn]; ok {
+			continue
+		}
+		var id, license interface{}
+		if r.ID != nil {
+			id = *r.ID
+		}
+		if r.License != nil {
+			license = r.License.SPDXID
+		}
+		res, err := tx.Exec(`INSERT INTO repos VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, rn, r.GetOwner().GetLogin(), r.Description, r.GetStargazersCount(), r.GetForksCount(),
+			r.GetOpenIssuesCount(), license, r.GetFork(), r.GetArchived(), r.DefaultBranch,
+			timestamp(r.CreatedAt), timestamp(r.PushedAt), timestamp(r.UpdatedAt))
+		if err != nil {
+			return fmt.Errorf("failed to insert repo %s: %v", rn, err)
+		}
+		if repoIDs[rn], err = res.LastInsertId(); err != nil {
+			return err
+		}
+		for _, t := range r.Topics {
+			if _, err = tx.Exec(`INSERT OR IGNORE INTO repo_topics VALUES (?, ?)`, repoIDs[rn], t); err != nil {
+				return fmt.Errorf("failed to insert topic of %s: %v", rn, err)
+			}
+		}
+	}
+
+	found := map[string]mod{}
+	for _, m := range modules {
+		found[m.Path] = m
+	}
+	for name, id := range nodes {
+		var repoID, modFile, workspace interface{}
+		m, ok := found[name]
+		if ok {
+			modFile, workspace = m.File, m.Workspace
+			if rid, ok := repoIDs[m.Repo]; ok {
+				repoID = rid
+			}
+		}
+		if _, err = tx.Exec(`INSERT INTO modules VALUES (?, ?, ?, ?, ?)`, id, name, repoID, modFile, workspace); err != nil {
+			return fmt.Errorf("failed to insert module %s: %v", name, err)
+		}
+		for _, a := range m.Alternates {
+			if _, err = tx.Exec(`INSERT INTO module_alternates VALUES (?, ?)`, id, a); err != nil {
+				return fmt.Errorf("failed to insert alternate of %s: %v", name, err)
+			}
+		}
+	}
+
+	// all the dependencies of the modules are exported, the ones excluded from the ranking
+	// (-no-test, -no-tool, -exclude-internal) have no weight
+	weights := map[[2]uint32]float64{}
+	for s, deps := range dg.Deps {
+		for _, d := range deps {
+			if d.Upstream {
+				weights[[2]uint32{s, d.PkgID}] = d.Weight
+			}
+		}
+	}
+	for _, m := range modules {
+		s := nodes[m.Path]
+		for _, dn := range m.DirectDeps {
+			d := nodes[dn]
+			var weight interface{}
+			if w, ok := weights[[2]uint32{s, d}]; ok {
+				weight = w
+			}
+			_, err = tx.Exec(`INSERT OR IGNORE INTO dependencies VALUES (?, ?, ?, ?, ?, ?)`,
+				s, d, m.Versions[dn], m.Kinds[dn], m.Internal[dn], weight)
+			if err != nil {
+				return fmt.Errorf("failed to insert dependency %s -> %s: %v", m.Path, dn, err)
+			}
+		}
+	}
+	for _, p := range dg.Pkgs {
+		_, err = tx.Exec(`INSERT INTO ranks VALUES (?, ?, ?, ?, ?, ?)`, p.ID, p.Rank, p.PRank, p.GRank, p.SRank, p.Imports)
+		if err != nil {
+			return fmt.Errorf("failed to insert rank of %s: %v", p.ModuleName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func timestamp(t *github.Timestamp) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,213 @@
+package ranker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// posColumn is the position of the module in the ranking, the other columns are the pkg fields.
+const posColumn = "pos"
+
+const defaultColumns = "pos,srank,prank,name,rank,stars,imports"
+
+// pkgColumns returns the index of the pkg field of every column, -1 for the position.
+func pkgColumns(cols []string) ([]int, error) {
+	fields := map[string]int{}
+	t := reflect.TypeOf(dgraph.Pkg{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = i
+	}
+	idx := make([]int, len(cols))
+	for i, c := range cols {
+		if c == posColumn {
+			idx[i] = -1
+			continue
+		}
+		f, ok := fields[c]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s", c)
+		}
+		idx[i] = f
+	}
+	return idx, nil
+}
+
+func pkgRow(pos int, p dgraph.Pkg, idx []int) []string {
+	v := reflect.ValueOf(p)
+	row := make([]string, len(idx))
+	for i, f := range idx {
+		if f < 0 {
+			row[i] = strconv.Itoa(pos)
+			continue
+		}
+		fv := v.Field(f)
+		switch fv.Kind() {
+		case reflect.String:
+			row[i] = fv.String()
+		case reflect.Float64:
+			row[i] = strconv.FormatFloat(fv.Float(), 'g', -1, 64)
+		case reflect.Slice:
+			if l, ok := fv.Interface().([]string); ok {
+				row[i] = strings.Join(l, ",")
+				break
+			}
+			fallthrough
+		case reflect.Struct, reflect.Ptr, reflect.Map:
+			if fv.IsZero() {
+				break
+			}
+			b, _ := json.Marshal(fv.Interface())
+			row[i] = string(b)
+		default:
+			row[i] = fmt.Sprint(fv.Interface())
+		}
+	}
+	return row
+}
+
+// writeTable writes the ranking, without the seed modules, with a header to fn or to stdout,
+// followed by a section for every topic ranking with the topic rank and position of the modules
+// other than the seeds.
+func writeTable(fn, format string, cols []string, dg dgraph.Graph) error {
+	idx, err := pkgColumns(cols)
+	if err != nil {
+		return err
+	}
+	if fn == "" {
+		return writeRanking(os.Stdout, format, cols, idx, dg)
+	}
+	out, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = writeRanking(out, format, cols, idx, dg)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeRanking(out io.Writer, format string, cols []string, idx []int, dg dgraph.Graph) error {
+	tw, err := newTableWriter(out, format)
+	if err != nil {
+		return err
+	}
+	if err = tw.Write(cols); err != nil {
+		return err
+	}
+	pos := 0
+	for _, p := range dg.Pkgs {
+		if p.Seed {
+			continue
+		}
+		if err = tw.Write(pkgRow(pos, p, idx)); err != nil {
+			return err
+		}
+		pos++
+	}
+	byID := make(map[uint32]int, len(dg.Pkgs))
+	for i, p := range dg.Pkgs {
+		byID[p.ID] = i
+	}
+	topicCols := append(append([]string{}, cols...), "topic_rank", "topic_prank")
+	for _, tr := range dg.TopicRankings {
+		if err = tw.Section("topic: " + tr.Topic); err != nil {
+			return err
+		}
+		if err = tw.Write(topicCols); err != nil {
+			return err
+		}
+		pos := 0
+		for _, m := range tr.Modules {
+			p := dg.Pkgs[byID[m.ID]]
+			if p.Seed {
+				continue
+			}
+			row := append(pkgRow(pos, p, idx), strconv.FormatFloat(m.Rank, 'g', -1, 64), strconv.Itoa(m.PRank))
+			if err = tw.Write(row); err != nil {
+				return err
+			}
+			pos++
+		}
+	}
+	return tw.Flush()
+}
+
+type tableWriter interface {
+	Write(row []string) error
+	// Section starts a new table with a title, csv and tsv tables are separated by an empty line
+	// and a line with the title prefixed by #.
+	Section(title string) error
+	Flush() error
+}
+
+// newTableWriter returns a writer of csv, tsv or markdown tables.
+func newTableWriter(w io.Writer, format string) (tableWriter, error) {
+	switch format {
+	case "csv":
+		return csvWriter{csv.NewWriter(w), w}, nil
+	case "tsv":
+		cw := csv.NewWriter(w)
+		cw.Comma = '\t'
+		return csvWriter{cw, w}, nil
+	case "md":
+		return &mdWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown table format %s", format)
+}
+
+type csvWriter struct {
+	*csv.Writer
+	w io.Writer
+}
+
+func (w csvWriter) Section(title string) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w.w, "\n# %s\n", title)
+	return err
+}
+
+func (w csvWriter) Flush() error {
+	w.Writer.Flush()
+	return w.Error()
+}
+
+type mdWriter struct {
+	w    io.Writer
+	rows int
+}
+
+var mdEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+
+func (w *mdWriter) Write(row []string) error {
+	cells := make([]string, len(row))
+	for i, c := range row {
+		cells[i] = mdEscaper.Replace(c)
+	}
+	_, err := fmt.Fprintf(w.w, "| %s |\n", strings.Join(cells, " | "))
+	if err == nil && w.rows == 0 {
+		_, err = fmt.Fprintf(w.w, "|%s\n", strings.Repeat("---|", len(row)))
+	}
+	w.rows++
+	return err
+}
+
+func (w *mdWriter) Section(title string) error {
+	w.rows = 0
+	_, err := fmt.Fprintf(w.w, "\n## %s\n\n", mdEscaper.Replace(title))
+	return err
+}
+
+func (w *mdWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package ranker
+
+import (
+	"sort"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+// rankTopics ranks the modules of every topic having at least minSize modules. The modules of a
+// topic keep their order of the global ranking and get their rank personalized for the topic:
+// the PageRank teleporting only to the modules of the topic, which favours the modules used by
+// the other modules of the topic. Like in the global ranking, modules with equal rank share their
+// position and the seed modules get no position. The topics are ordered by decreasing size.
+func rankTopics(dg dgraph.Graph, minSize int, α, ε float64) []dgraph.TopicRanking {
+	members := map[string][]int{}
+	for i, p := range dg.Pkgs {
+		for _, t := range p.Topics {
+			members[t] = append(members[t], i)
+		}
+	}
+	var topics []string
+	for t, ms := range members {
+		if len(ms) >= minSize {
+			topics = append(topics, t)
+		}
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		ti, tj := topics[i], topics[j]
+		if len(members[ti]) != len(members[tj]) {
+			return len(members[ti]) > len(members[tj])
+		}
+		return ti < tj
+	})
+	var rankings []dgraph.TopicRanking
+	for _, t := range topics {
+		seeds := make([]uint32, len(members[t]))
+		for i, m := range members[t] {
+			seeds[i] = dg.Pkgs[m].ID
+		}
+		ranks := map[uint32]float64{}
+		personalizedRank(dg, seeds, α, ε, func(id uint32, rank float64) {
+			ranks[id] = rank
+		})
+		tr := dgraph.TopicRanking{Topic: t, Modules: make([]dgraph.TopicRank, len(seeds))}
+		for i, id := range seeds {
+			tr.Modules[i] = dgraph.TopicRank{ID: id, Rank: ranks[id]}
+		}
+		byRank := make([]int, len(tr.Modules))
+		for i := range byRank {
+			byRank[i] = i
+		}
+		sort.SliceStable(byRank, func(i, j int) bool { return tr.Modules[byRank[i]].Rank > tr.Modules[byRank[j]].Rank })
+		rank, prev := 0, -1.0
+		for _, m := range byRank {
+			if dg.Pkgs[members[t][m]].Seed {
+				continue
+			}
+			if tr.Modules[m].Rank != prev {
+				rank++
+			}
+			prev = tr.Modules[m].Rank
+			tr.Modules[m].PRank = rank
+		}
+		rankings = append(rankings, tr)
+	}
+	return rankings
+}
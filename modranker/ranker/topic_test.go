@@ -0,0 +1,34 @@
+package ranker
+
+import (
+	"testing"
+
+	"github.com/hullarb/grank/modranker/dgraph"
+)
+
+func TestRankTopicsTies(t *testing.T) {
+	const a, b, c, d, s = 1, 2, 3, 4, 5
+	dg := testGraph(map[uint32][]dgraph.Dependency{
+		c: {{PkgID: a, Weight: 1}, {PkgID: b, Weight: 1}},
+		d: {{PkgID: c, Weight: 1}},
+		s: {{PkgID: c, Weight: 1}},
+	})
+	for _, id := range []uint32{a, b, c, d, s} {
+		dg.Pkgs = append(dg.Pkgs, dgraph.Pkg{ID: id, Topics: []string{"x"}, Seed: id == s})
+	}
+	rankings := rankTopics(dg, 1, 0.85, 1e-9)
+	if len(rankings) != 1 {
+		t.Fatalf("got %d topic rankings, want 1", len(rankings))
+	}
+	prank := map[uint32]int{}
+	for _, m := range rankings[0].Modules {
+		prank[m.ID] = m.PRank
+	}
+	// a and b share the second position and d follows them without a gap, the seed gets no position
+	want := map[uint32]int{c: 1, a: 2, b: 2, d: 3, s: 0}
+	for id, p := range want {
+		if prank[id] != p {
+			t.Errorf("module %d: got prank %d, want %d", id, prank[id], p)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ranker
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// weighter returns the weight of the edges going out of the modules of a repository.
+type weighter func(r *github.Repository) float64
+
+const (
+	defaultHalfLife      = 365 * 24 * time.Hour
+	defaultUnknownWeight = 1.0
+)
+
+// weighters returns the edge weightings by their name.
+func (rr *rankRun) weighters() map[string]weighter {
+	return map[string]weighter{
+		"stars": func(r *github.Repository) float64 {
+			return float64(r.GetStargazersCount())
+		},
+		"logstars": func(r *github.Repository) float64 {
+			return math.Log(float64(r.GetStargazersCount()) + 1)
+		},
+		"uniform": func(r *github.Repository) float64 {
+			return 1
+		},
+		"forks": func(r *github.Repository) float64 {
+			return float64(r.GetForksCount())
+		},
+		// activity halves with every halfLife elapsed since the last push
+		"activity": rr.activity,
+		"decay": func(r *github.Repository) float64 {
+			return float64(r.GetStargazersCount()) * rr.activity(r)
+		},
+	}
+}
+
+func (rr *rankRun) activity(r *github.Repository) float64 {
+	if r.PushedAt == nil {
+		return 1
+	}
+	age := rr.now.Sub(r.PushedAt.Time)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(rr.halfLife))
+}
+
+// parseWeighter builds a weighter from a spec like "logstars*activity+uniform":
+// the terms of a '*' separated group are multiplied and the groups are summed up.
+func (rr *rankRun) parseWeighter(spec string) (weighter, error) {
+	weighters := rr.weighters()
+	var sum []weighter
+	for _, g := range strings.Split(spec, "+") {
+		var prod []weighter
+		for _, n := range strings.Split(g, "*") {
+			n = strings.TrimSpace(n)
+			wf, ok := weighters[n]
+			if !ok {
+				return nil, fmt.Errorf("unknown weighting %q", n)
+			}
+			prod = append(prod, wf)
+		}
+		sum = append(sum, func(r *github.Repository) float64 {
+			v := 1.0
+			for _, wf := range prod {
+				v *= wf(r)
+			}
+			return v
+		})
+	}
+	return func(r *github.Repository) float64 {
+		var v float64
+		for _, wf := range sum {
+			v += wf(r)
+		}
+		return v
+	}, nil
+}
+
+// edgeWeight returns the weight of the dependencies of the modules in repo.
+func (rr *rankRun) edgeWeight(repo string) float64 {
+	if wt, ok := rr.weights[repo]; ok {
+		return wt
+	}
+	return rr.unknownWeight
+}
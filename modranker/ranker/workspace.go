@@ -0,0 +1,52 @@
+package ranker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// parseMod parses a go.mod file with its replace directives, which modfile.ParseLax drops.
+// The files the strict parser rejects, e.g. with a directive newer than modfile, are parsed lax
+// without them.
+func parseMod(path string, data []byte) (*modfile.File, error) {
+	if f, err := modfile.Parse(path, data, anyVersion); err == nil {
+		return f, nil
+	}
+	return modfile.ParseLax(path, data, nil)
+}
+
+// anyVersion keeps the versions of the mod file as they are, they are only recorded.
+func anyVersion(path, vers string) (string, error) {
+	return vers, nil
+}
+
+// localReplaces returns the modules which are replaced by a directory in the mod file,
+// e.g. a nested module of the same repository: replace example.com/repo/sub => ./sub
+func localReplaces(f *modfile.File) []string {
+	var local []string
+	for _, r := range f.Replace {
+		if r.New.Version == "" && modfile.IsDirectoryPath(r.New.Path) {
+			local = append(local, r.Old.Path)
+		}
+	}
+	return local
+}
+
+// workspaceDirs returns the module directories used by the go.work file at path.
+func workspaceDirs(path string) ([]string, error) {
+	c, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseWork(path, c, nil)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, u := range f.Use {
+		dirs = append(dirs, filepath.Join(filepath.Dir(path), u.Path))
+	}
+	return dirs, nil
+}
@@ -0,0 +1,146 @@
+package ranker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hullarb/grank/fetcharchive/fetcher"
+)
+
+func TestWorkspaceDirs(t *testing.T) {
+	tests := []struct {
+		name string
+		work string
+		want []string
+	}{
+		{"minor version", "go 1.18\n\nuse ./a\n", []string{"a"}},
+		{"patch version", "go 1.22.0\n\ntoolchain go1.22.1\n\nuse (\n\t./a\n\t\"./b c\"\n)\n", []string{"a", "b c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "grank")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "go.work")
+			if err = ioutil.WriteFile(path, []byte(tt.work), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := workspaceDirs(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, w := range tt.want {
+				want = append(want, filepath.Join(dir, w))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMod(t *testing.T) {
+	tests := []struct {
+		name     string
+		mod      string
+		requires int
+		local    []string
+	}{
+		{"patch version", "module example.com/m\n\ngo 1.21.0\n\nrequire example.com/d v1.0.0\n", 1, nil},
+		{"local replace", "module example.com/m\n\nrequire example.com/m/sub v0.0.0\n\nreplace (\n\texample.com/m/sub => ./sub\n\texample.com/x => example.com/y v1.0.0\n)\n", 1, []string{"example.com/m/sub"}},
+		{"unknown directive", "module example.com/m\n\nfrobnicate on\n\nrequire example.com/d v1.0.0\n", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseMod("go.mod", []byte(tt.mod))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Module.Mod.Path != "example.com/m" || len(f.Require) != tt.requires {
+				t.Errorf("got module %s with %d requires", f.Module.Mod.Path, len(f.Require))
+			}
+			if got := localReplaces(f); !reflect.DeepEqual(got, tt.local) {
+				t.Errorf("got local replaces %v, want %v", got, tt.local)
+			}
+		})
+	}
+}
+
+// The workspaces of the repositories extracted by fetcharchive are found.
+func TestFindModulesFetched(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	files := []struct{ name, body string }{
+		{"o-r-0123abc/", ""},
+		{"o-r-0123abc/go.work", "go 1.22.0\n\nuse (\n\t./a\n\t./b\n)\n"},
+		{"o-r-0123abc/go.work.sum", ""},
+		{"o-r-0123abc/README.md", "r"},
+		{"o-r-0123abc/a/", ""},
+		{"o-r-0123abc/a/go.mod", "module github.com/o/r/a\n\ngo 1.22.0\n\nrequire github.com/o/r/b v1.0.0\n"},
+		{"o-r-0123abc/a/a.go", "package a\n\nimport _ \"github.com/o/r/b\"\n"},
+		{"o-r-0123abc/b/", ""},
+		{"o-r-0123abc/b/go.mod", "module github.com/o/r/b\n\ngo 1.22.0\n"},
+	}
+	for _, f := range files {
+		h := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.body))}
+		if strings.HasSuffix(f.name, "/") {
+			h.Typeflag, h.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "grank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "github.com", "o", "r")
+	if err = os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = fetcher.Extract(repo, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(repo, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("README.md was extracted")
+	}
+	rr := newRankRun()
+	rr.downloadDir = dir
+	modules, err := rr.findModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 2 {
+		t.Fatalf("got %d modules, want 2", len(modules))
+	}
+	for _, m := range modules {
+		if m.Workspace != "github.com/o/r/go.work" {
+			t.Errorf("%s: got workspace %q", m.Path, m.Workspace)
+		}
+		if m.Path == "github.com/o/r/a" && !m.Internal["github.com/o/r/b"] {
+			t.Errorf("%s: the dependency of the same repository and workspace is not internal", m.Path)
+		}
+	}
+}